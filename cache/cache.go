@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"encoding/gob"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Dir returns the path of elem within cstatus's cache directory, or "" when
+// the user has no cache directory.
+func Dir(elem ...string) string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(append([]string{cacheDir, "cstatus"}, elem...)...)
+}
+
+// Read decodes the file at path into v, reporting whether it succeeded. A
+// missing file is expected; one that cannot be decoded is logged.
+func Read(path string, v any) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	if err := gob.NewDecoder(file).Decode(v); err != nil {
+		log.Printf("Warning: discarding unreadable cache file %s: %v", path, err)
+		return false
+	}
+	return true
+}
+
+// Write atomically replaces the file at path with the encoding of v, so
+// concurrent sessions never read a partially written cache.
+func Write(path string, v any) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".cache-*.gob")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(v); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to encode cache: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace cache: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package claude
+
+import (
+	"sort"
+	"time"
+)
+
+type ClaudeBlockMetrics struct {
+	StartTime    time.Time `json:"startTime"`
+	EndTime      time.Time `json:"endTime"`
+	LastActivity time.Time `json:"lastActivity"`
+
+	// Usage and cost across every session during the block
+	Usage   UsageTotals `json:"usage"`
+	CostUSD float64     `json:"costUSD"`
+
+	BurnRate BurnRate `json:"burnRate"`
+
+	// Projected totals at EndTime if the current burn rate continues
+	ProjectedTokens  int64   `json:"projectedTokens"`
+	ProjectedCostUSD float64 `json:"projectedCostUSD"`
+}
+
+// BurnRate is the rate of usage over the last burnRateWindow of the block.
+type BurnRate struct {
+	TokensPerMinute      float64 `json:"tokensPerMinute"`
+	LimitTokensPerMinute float64 `json:"limitTokensPerMinute"`
+	CostPerMinute        float64 `json:"costPerMinute"`
+}
+
+// burnRateWindow is the sliding window burn rates are measured over.
+const burnRateWindow = 30 * time.Minute
+
+// Elapsed returns how long the block has been running at now.
+func (b *ClaudeBlockMetrics) Elapsed(now time.Time) time.Duration {
+	return now.Sub(b.StartTime)
+}
+
+// Remaining returns the time left until the block resets at now.
+func (b *ClaudeBlockMetrics) Remaining(now time.Time) time.Duration {
+	return max(b.EndTime.Sub(now), 0)
+}
+
+// Progress returns the fraction of the block that has elapsed at now.
+func (b *ClaudeBlockMetrics) Progress(now time.Time) float64 {
+	total := b.EndTime.Sub(b.StartTime)
+	if total <= 0 {
+		return 0
+	}
+	return min(max(float64(now.Sub(b.StartTime))/float64(total), 0), 1)
+}
+
+// SessionDuration represents the session duration in milliseconds (5 hours)
+const sessionDurationMs = int64(5 * 60 * 60 * 1000)
+
+// aggregate totals the block's usage and, for the block active at now,
+// derives the burn rate and projection. Blocks start on the hour, so hourly
+// buckets cover them exactly.
+func (b *ClaudeBlockMetrics) aggregate(h *UsageHistory, now time.Time) {
+	used := h.hourly.between(hourKey(b.StartTime), hourKey(b.EndTime))
+	b.Usage = used.Totals()
+	b.CostUSD = used.CostUSD()
+
+	if now.Before(b.StartTime) || !now.Before(b.EndTime) {
+		return
+	}
+
+	window := min(burnRateWindow, b.Elapsed(now))
+	window = max(window, time.Minute)
+
+	recent := h.recent.between(minuteKey(now.Add(-window)), minuteKey(now)+1)
+	recentTotals := recent.Totals()
+	b.BurnRate = BurnRate{
+		TokensPerMinute:      float64(recentTotals.TotalTokens()) / window.Minutes(),
+		LimitTokensPerMinute: float64(recentTotals.LimitTokens()) / window.Minutes(),
+		CostPerMinute:        recent.CostUSD() / window.Minutes(),
+	}
+
+	remaining := b.Remaining(now).Minutes()
+	b.ProjectedTokens = b.Usage.TotalTokens() + int64(b.BurnRate.TokensPerMinute*remaining)
+	b.ProjectedCostUSD = b.CostUSD + b.BurnRate.CostPerMinute*remaining
+}
+
+// activityRun is a period of continuous work: consecutive timestamps within
+// it are less than a session duration apart.
+type activityRun struct {
+	Start time.Time
+	End   time.Time
+}
+
+// addActivity records a timestamp, extending or merging runs as needed.
+func addActivity(runs []activityRun, t time.Time) []activityRun {
+	return addRun(runs, activityRun{Start: t, End: t})
+}
+
+// addRun adds a run to a list of runs, merging any that end up less than a
+// session duration apart. Runs are kept sorted by start time.
+func addRun(runs []activityRun, run activityRun) []activityRun {
+	gap := time.Duration(sessionDurationMs) * time.Millisecond
+
+	// Fast path: transcripts are appended in chronological order
+	if n := len(runs); n > 0 && !run.Start.Before(runs[n-1].Start) {
+		last := &runs[n-1]
+		if run.Start.Sub(last.End) < gap {
+			if run.End.After(last.End) {
+				last.End = run.End
+			}
+			return runs
+		}
+		return append(runs, run)
+	}
+
+	runs = append(runs, run)
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Start.Before(runs[j].Start)
+	})
+
+	merged := runs[:1]
+	for _, run := range runs[1:] {
+		last := &merged[len(merged)-1]
+		if run.Start.Sub(last.End) < gap {
+			if run.End.After(last.End) {
+				last.End = run.End
+			}
+			continue
+		}
+		merged = append(merged, run)
+	}
+	return merged
+}
+
+// calculateBlockMetrics computes the block active at now from sorted activity runs
+func calculateBlockMetrics(runs []activityRun, now time.Time) *ClaudeBlockMetrics {
+	if len(runs) == 0 {
+		return nil
+	}
+
+	current := runs[len(runs)-1]
+
+	// Check if the most recent activity is within the current session period
+	if now.Sub(current.End).Milliseconds() > sessionDurationMs {
+		return nil // No recent activity
+	}
+
+	// Floor the start of the current continuous work period to the hour
+	flooredWorkStart := floorToHour(current.Start)
+
+	// Calculate current block within the work period
+	blockStart := calculateBlockStart(now, flooredWorkStart, sessionDurationMs)
+
+	return &ClaudeBlockMetrics{
+		StartTime:    blockStart,
+		EndTime:      blockStart.Add(time.Duration(sessionDurationMs) * time.Millisecond),
+		LastActivity: current.End,
+	}
+}
+
+// floorToHour floors a timestamp to the hour boundary
+func floorToHour(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
+
+// calculateBlockStart determines the start time of the current block
+func calculateBlockStart(now, flooredWorkStart time.Time, sessionDurationMs int64) time.Time {
+	totalWorkTime := now.Sub(flooredWorkStart).Milliseconds()
+	if totalWorkTime > sessionDurationMs {
+		completedBlocks := totalWorkTime / sessionDurationMs
+		blockStartMs := flooredWorkStart.UnixMilli() + (completedBlocks * sessionDurationMs)
+		return time.UnixMilli(blockStartMs)
+	}
+	return flooredWorkStart
+}
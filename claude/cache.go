@@ -0,0 +1,558 @@
+package claude
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/CS-5/cstatus/cache"
+)
+
+// transcriptCacheVersion must be bumped whenever transcriptSummary or
+// ownerIndex changes so stale caches are discarded rather than misread.
+const transcriptCacheVersion = 9
+
+// ownersFile is the name of the owner index within the cache directory.
+const ownersFile = "owners.gob"
+
+// orphansFile is the name of the orphaned usage keys within the cache
+// directory. It only exists while there are any.
+const orphansFile = "orphans.gob"
+
+// transcriptCache persists per-transcript summaries between statusline
+// refreshes. Each summary records how far into the file it has read, so a
+// refresh only needs to parse lines appended since the previous one.
+//
+// Every transcript's summary is stored in its own file, so a refresh only
+// rewrites the summaries of transcripts that changed. The owner index is
+// only loaded when new lines need to be parsed.
+type transcriptCache struct {
+	dir string
+	// now is the time the cache is used at, which decides what per-minute
+	// usage is still worth keeping
+	now time.Time
+	// until, when set, leaves lines written after it unread, so transcripts
+	// are summarized as they were at that time
+	until time.Time
+
+	files map[string]*transcriptSummary
+	dirty map[string]bool
+
+	owners *ownerIndex
+
+	// orphans holds usage keys whose owner was released, such as when its
+	// transcript was deleted. Transcripts holding copies of them are
+	// reparsed so one of them claims the usage. Loaded on first use.
+	orphans      map[uint64]bool
+	orphansDirty bool
+}
+
+// cachedOrphans is the on-disk form of the orphaned usage keys.
+type cachedOrphans struct {
+	Version int
+	Hashes  map[uint64]bool
+}
+
+// cachedSummary is the on-disk form of a transcript's summary.
+type cachedSummary struct {
+	Version int
+	Path    string
+	Summary *transcriptSummary
+}
+
+// ownerIndex maps the hash of each usage key to the transcript whose buckets
+// count it, so responses copied into resumed sessions are counted once
+// across the account. Transcripts are referred to by a small ID rather than
+// their path to keep the index compact.
+type ownerIndex struct {
+	Version int
+	IDs     map[string]uint32
+	NextID  uint32
+
+	// Hashes is sorted and Owners[i] owns Hashes[i]. Sorted slices decode
+	// much faster than a map of the same size; claims made since loading
+	// are kept in added until the index is saved.
+	Hashes []uint64
+	Owners []uint32
+
+	added map[uint64]uint32
+	dirty bool
+}
+
+// transcriptSummary holds everything derived from a transcript up to Offset.
+// Inode, Size and ModTime identify the file version the summary was built from.
+type transcriptSummary struct {
+	Inode   uint64
+	Size    int64
+	ModTime time.Time
+	Offset  int64
+
+	Tokens           ClaudeTokenMetrics
+	Subagents        ClaudeSubagentMetrics
+	ContextTimestamp time.Time
+	Runs             []activityRun
+
+	// Copied holds hashes of usage keys the transcript shares with another
+	// transcript that owns them, so repeats are skipped. Keys the transcript
+	// owns are tracked by the owner index instead.
+	Copied map[uint64]bool
+
+	// Hourly holds usage by hour for account-wide reporting. Recent holds
+	// usage by minute for burn rates and only covers the last block.
+	Hourly usageBuckets
+	Recent usageBuckets
+}
+
+// recentRetentionMinutes is how much per-minute usage a summary keeps.
+const recentRetentionMinutes = sessionDurationMs / 60000
+
+// initMaps allocates the summary's maps. Gob does not transmit empty maps, so
+// they may be nil after loading the cache.
+func (s *transcriptSummary) initMaps() {
+	if s.Tokens.Models == nil {
+		s.Tokens.Models = ModelUsage{}
+	}
+	if s.Subagents.Usage == nil {
+		s.Subagents.Usage = ModelUsage{}
+	}
+	if s.Subagents.Tasks == nil {
+		s.Subagents.Tasks = map[string]bool{}
+	}
+	if s.Copied == nil {
+		s.Copied = map[uint64]bool{}
+	}
+	if s.Hourly == nil {
+		s.Hourly = usageBuckets{}
+	}
+	if s.Recent == nil {
+		s.Recent = usageBuckets{}
+	}
+}
+
+func transcriptCacheDir() string {
+	return cache.Dir("transcripts")
+}
+
+// loadTranscriptCache returns a cache backed by the cache directory.
+// Summaries are read as they are needed; a missing, unreadable or outdated
+// file is treated as absent since the cache is only an optimization.
+func loadTranscriptCache() *transcriptCache {
+	return newTranscriptCache(transcriptCacheDir())
+}
+
+// newTranscriptCache returns a cache stored in dir. An empty dir keeps the
+// cache in memory only.
+func newTranscriptCache(dir string) *transcriptCache {
+	return &transcriptCache{
+		dir:   dir,
+		now:   time.Now(),
+		files: map[string]*transcriptSummary{},
+		dirty: map[string]bool{},
+	}
+}
+
+// summaryPath returns where the summary of the transcript at path is stored.
+func (c *transcriptCache) summaryPath(path string) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(path))
+	return filepath.Join(c.dir, fmt.Sprintf("%016x.gob", hash.Sum64()))
+}
+
+// load returns the stored summary of the transcript at path, or nil.
+func (c *transcriptCache) load(path string) *transcriptSummary {
+	if summary, ok := c.files[path]; ok {
+		return summary
+	}
+	if c.dir == "" {
+		return nil
+	}
+
+	var stored cachedSummary
+	if !cache.Read(c.summaryPath(path), &stored) || stored.Version != transcriptCacheVersion ||
+		stored.Path != path || stored.Summary == nil {
+		return nil
+	}
+	stored.Summary.initMaps()
+	c.files[path] = stored.Summary
+	return stored.Summary
+}
+
+// ownerIndex loads the owner index on first use.
+func (c *transcriptCache) ownerIndex() *ownerIndex {
+	if c.owners != nil {
+		return c.owners
+	}
+
+	var stored ownerIndex
+	if c.dir == "" || !cache.Read(filepath.Join(c.dir, ownersFile), &stored) ||
+		stored.Version != transcriptCacheVersion || len(stored.Hashes) != len(stored.Owners) {
+		stored = ownerIndex{Version: transcriptCacheVersion}
+	}
+	if stored.IDs == nil {
+		stored.IDs = map[string]uint32{}
+	}
+	stored.added = map[uint64]uint32{}
+	c.owners = &stored
+	return c.owners
+}
+
+// save writes the summaries and owner index that changed. Files are replaced
+// atomically so concurrent sessions never read a partially written cache.
+func (c *transcriptCache) save() error {
+	if c.dir == "" {
+		return nil
+	}
+	if len(c.dirty) == 0 && (c.owners == nil || !c.owners.dirty) && !c.orphansDirty {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %v", err)
+	}
+
+	for path := range c.dirty {
+		summaryPath := c.summaryPath(path)
+		summary := c.files[path]
+		if summary == nil {
+			if err := os.Remove(summaryPath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove cached summary: %v", err)
+			}
+		} else {
+			stored := cachedSummary{Version: transcriptCacheVersion, Path: path, Summary: summary}
+			if err := cache.Write(summaryPath, &stored); err != nil {
+				return err
+			}
+		}
+		delete(c.dirty, path)
+	}
+
+	if c.owners != nil && c.owners.dirty {
+		c.owners.compact()
+		if err := cache.Write(filepath.Join(c.dir, ownersFile), c.owners); err != nil {
+			return err
+		}
+		c.owners.dirty = false
+	}
+
+	if c.orphansDirty {
+		orphansPath := filepath.Join(c.dir, orphansFile)
+		if len(c.orphans) == 0 {
+			if err := os.Remove(orphansPath); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove orphaned usage keys: %v", err)
+			}
+		} else if err := cache.Write(orphansPath, &cachedOrphans{Version: transcriptCacheVersion, Hashes: c.orphans}); err != nil {
+			return err
+		}
+		c.orphansDirty = false
+	}
+	return nil
+}
+
+// orphanSet loads the orphaned usage keys on first use.
+func (c *transcriptCache) orphanSet() map[uint64]bool {
+	if c.orphans != nil {
+		return c.orphans
+	}
+
+	var stored cachedOrphans
+	if c.dir != "" && cache.Read(filepath.Join(c.dir, orphansFile), &stored) &&
+		stored.Version == transcriptCacheVersion && stored.Hashes != nil {
+		c.orphans = stored.Hashes
+	} else {
+		c.orphans = map[uint64]bool{}
+	}
+	return c.orphans
+}
+
+// holdsOrphans reports whether a summary skipped usage whose owner has since
+// been released.
+func (c *transcriptCache) holdsOrphans(summary *transcriptSummary) bool {
+	orphans := c.orphanSet()
+	if len(orphans) == 0 || len(summary.Copied) == 0 {
+		return false
+	}
+	small, large := orphans, summary.Copied
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	for hash := range small {
+		if large[hash] {
+			return true
+		}
+	}
+	return false
+}
+
+// dropOrphans forgets orphaned keys once every transcript that could hold
+// them has been summarized. Keys nobody claimed belong to no transcript.
+func (c *transcriptCache) dropOrphans(hashes map[uint64]bool) {
+	orphans := c.orphanSet()
+	for hash := range hashes {
+		if orphans[hash] {
+			delete(orphans, hash)
+			c.orphansDirty = true
+		}
+	}
+}
+
+// summarize returns an up-to-date summary of the transcript at path, parsing
+// only the bytes appended since the cached summary was built. The transcript
+// is parsed from the start when it was truncated or replaced. After a read
+// error the summary of the lines read so far is returned with the error.
+func (c *transcriptCache) summarize(path string) (*transcriptSummary, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	inode := fileInode(info)
+
+	summary := c.load(path)
+	if summary != nil && c.holdsOrphans(summary) {
+		// Usage copied here lost its owner, so parse again to claim it
+		c.release(path)
+		summary = nil
+	}
+	if summary != nil {
+		unchanged := summary.Size == info.Size() && summary.ModTime.Equal(info.ModTime())
+		if summary.Inode == inode && unchanged {
+			if summary.pruneRecent(c.now) {
+				c.dirty[path] = true
+			}
+			return summary, nil
+		}
+
+		replaced := summary.Inode != inode || info.Size() < summary.Offset ||
+			(summary.Size == info.Size() && !summary.ModTime.Equal(info.ModTime()))
+		if replaced {
+			c.release(path)
+			summary = nil
+		}
+	}
+	if summary == nil {
+		summary = &transcriptSummary{}
+	}
+	summary.initMaps()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(summary.Offset, 0); err != nil {
+		return nil, fmt.Errorf("failed to seek transcript: %v", err)
+	}
+
+	reader := NewTranscriptReader(file)
+	read, complete := int64(0), true
+	for reader.Next() {
+		var record usageRecord
+		if err := reader.Decode(&record); err != nil {
+			// Log parsing errors for debugging, but continue processing
+			log.Printf("Warning: failed to parse transcript line: %v", err)
+			read = reader.Offset()
+			continue
+		}
+		if c.later(&record) {
+			complete = false
+			break
+		}
+		summary.apply(&record, func(hash uint64) (bool, bool) {
+			return c.claim(hash, path)
+		})
+		read = reader.Offset()
+	}
+	if complete {
+		read = reader.Offset()
+	}
+	summary.Offset += read
+	summary.pruneRecent(c.now)
+
+	if err := reader.Err(); err != nil {
+		// The lines read so far are consistent with Offset and remain valid
+		c.files[path] = summary
+		c.dirty[path] = true
+		return summary, fmt.Errorf("failed to read transcript: %w", err)
+	}
+
+	summary.Inode = inode
+	summary.Size = info.Size()
+	summary.ModTime = info.ModTime()
+	if !complete {
+		// Not up to date, so the rest is read once until moves on
+		summary.Size = -1
+	}
+	c.files[path] = summary
+	c.dirty[path] = true
+
+	return summary, nil
+}
+
+// later reports whether a transcript line was written after until.
+func (c *transcriptCache) later(record *usageRecord) bool {
+	if c.until.IsZero() || record.Timestamp == "" {
+		return false
+	}
+	t, err := time.Parse(time.RFC3339, record.Timestamp)
+	return err == nil && t.After(c.until)
+}
+
+// prune drops summaries of transcripts that no longer exist, such as those
+// removed by Claude Code's periodic cleanup.
+func (c *transcriptCache) prune(files []transcriptFile) {
+	if c.dir == "" {
+		return
+	}
+
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[filepath.Base(c.summaryPath(file.Path))] = true
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if present[name] || name == ownersFile || !strings.HasSuffix(name, ".gob") {
+			continue
+		}
+
+		// Transcripts outside the scanned roots may still exist
+		var stored cachedSummary
+		if !cache.Read(filepath.Join(c.dir, name), &stored) || stored.Version != transcriptCacheVersion {
+			os.Remove(filepath.Join(c.dir, name))
+			continue
+		}
+		if _, err := os.Stat(stored.Path); os.IsNotExist(err) {
+			delete(c.files, stored.Path)
+			c.dirty[stored.Path] = true
+			c.release(stored.Path)
+			c.forget(stored.Path)
+		}
+	}
+}
+
+// claim records that the transcript at path contains the usage key hash. It
+// reports whether path owns the hash, i.e. whether its buckets should count
+// the usage, and whether path had already claimed it.
+func (c *transcriptCache) claim(hash uint64, path string) (owned, repeat bool) {
+	index := c.ownerIndex()
+	id := index.id(path)
+	if owner, ok := index.owner(hash); ok {
+		return owner == id, owner == id
+	}
+	index.added[hash] = id
+	index.dirty = true
+	if orphans := c.orphanSet(); orphans[hash] {
+		delete(orphans, hash)
+		c.orphansDirty = true
+	}
+	return true, false
+}
+
+// release forgets the usage keys owned by path, before it is reparsed or
+// once it no longer exists. The keys are orphaned until another transcript
+// holding them, or path itself, claims them again.
+func (c *transcriptCache) release(path string) {
+	index := c.ownerIndex()
+	id, ok := index.IDs[path]
+	if !ok {
+		return
+	}
+	released := index.release(id)
+	if len(released) == 0 {
+		return
+	}
+	orphans := c.orphanSet()
+	for _, hash := range released {
+		orphans[hash] = true
+	}
+	c.orphansDirty = true
+}
+
+// forget drops the ID of a transcript that no longer exists.
+func (c *transcriptCache) forget(path string) {
+	index := c.ownerIndex()
+	if _, ok := index.IDs[path]; ok {
+		delete(index.IDs, path)
+		index.dirty = true
+	}
+}
+
+// id returns the ID of the transcript at path, assigning one if needed.
+func (i *ownerIndex) id(path string) uint32 {
+	if id, ok := i.IDs[path]; ok {
+		return id
+	}
+	i.NextID++
+	i.IDs[path] = i.NextID
+	i.dirty = true
+	return i.NextID
+}
+
+// owner returns the ID of the transcript owning hash.
+func (i *ownerIndex) owner(hash uint64) (uint32, bool) {
+	if id, ok := i.added[hash]; ok {
+		return id, true
+	}
+	if n, ok := slices.BinarySearch(i.Hashes, hash); ok {
+		return i.Owners[n], true
+	}
+	return 0, false
+}
+
+// release drops every hash owned by id and returns them.
+func (i *ownerIndex) release(id uint32) []uint64 {
+	var released []uint64
+	kept := 0
+	for n, owner := range i.Owners {
+		if owner != id {
+			i.Hashes[kept], i.Owners[kept] = i.Hashes[n], owner
+			kept++
+		} else {
+			released = append(released, i.Hashes[n])
+		}
+	}
+	if kept != len(i.Owners) {
+		i.Hashes, i.Owners = i.Hashes[:kept], i.Owners[:kept]
+		i.dirty = true
+	}
+	for hash, owner := range i.added {
+		if owner == id {
+			released = append(released, hash)
+			delete(i.added, hash)
+		}
+	}
+	return released
+}
+
+// compact merges the claims in added into the sorted slices.
+func (i *ownerIndex) compact() {
+	if len(i.added) == 0 {
+		return
+	}
+	added := slices.Sorted(maps.Keys(i.added))
+
+	hashes := make([]uint64, 0, len(i.Hashes)+len(added))
+	owners := make([]uint32, 0, len(i.Hashes)+len(added))
+	n := 0
+	for _, hash := range added {
+		for n < len(i.Hashes) && i.Hashes[n] < hash {
+			hashes, owners = append(hashes, i.Hashes[n]), append(owners, i.Owners[n])
+			n++
+		}
+		hashes, owners = append(hashes, hash), append(owners, i.added[hash])
+	}
+	hashes, owners = append(hashes, i.Hashes[n:]...), append(owners, i.Owners[n:]...)
+
+	i.Hashes, i.Owners = hashes, owners
+	clear(i.added)
+}
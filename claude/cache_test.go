@@ -0,0 +1,294 @@
+package claude
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResumedSessionCountsCopiedUsageOnce(t *testing.T) {
+	const original, resumed = "testdata/dedup/session.jsonl", "testdata/dedup/resumed.jsonl"
+
+	// Account-wide usage of msg_01 to msg_04, each counted once
+	want := UsageTotals{
+		InputTokens:         12 + 8 + 500 + 6,
+		OutputTokens:        340 + 120 + 40 + 75,
+		CacheReadTokens:     15000 + 17100 + 17500,
+		CacheCreationTokens: 2100 + 400,
+		Messages:            4,
+	}
+
+	for _, order := range [][]string{{original, resumed}, {resumed, original}} {
+		cache := newTranscriptCache("")
+		account := usageBuckets{}
+		summaries := map[string]*transcriptSummary{}
+		for _, path := range order {
+			summary, err := cache.summarize(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			summaries[path] = summary
+			account.merge(summary.Hourly)
+		}
+
+		if got := billedTotals(account); got != want {
+			t.Errorf("parsing %v: account usage = %+v, want %+v", order, got, want)
+		}
+
+		// Session totals include the copied conversation either way
+		if got := summaries[resumed].Tokens.InputTokens; got != 12+8+6 {
+			t.Errorf("parsing %v: resumed session input tokens = %d, want %d", order, got, 12+8+6)
+		}
+	}
+}
+
+func TestResumedSessionOwnership(t *testing.T) {
+	const original, resumed = "testdata/dedup/session.jsonl", "testdata/dedup/resumed.jsonl"
+
+	cache := newTranscriptCache("")
+	for _, path := range []string{original, resumed} {
+		if _, err := cache.summarize(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The original owns the copied responses; the resumed session only owns
+	// msg_04, and skips its repeated line
+	owned := billedTotals(cache.files[resumed].Hourly)
+	if owned.Messages != 1 || owned.OutputTokens != 75 {
+		t.Errorf("resumed session buckets = %+v, want only msg_04", owned)
+	}
+	if got := len(cache.files[resumed].Copied); got != 2 {
+		t.Errorf("resumed session copied keys = %d, want 2", got)
+	}
+}
+
+func TestClaim(t *testing.T) {
+	const hash = 42
+	cache := newTranscriptCache("")
+
+	steps := []struct {
+		name         string
+		path         string
+		owned, again bool
+	}{
+		{"first claim", "a.jsonl", true, false},
+		{"repeat in owner", "a.jsonl", true, true},
+		{"copy in other file", "b.jsonl", false, false},
+	}
+	for _, step := range steps {
+		owned, repeat := cache.claim(hash, step.path)
+		if owned != step.owned || repeat != step.again {
+			t.Errorf("%s: claim = (%v, %v), want (%v, %v)", step.name, owned, repeat, step.owned, step.again)
+		}
+	}
+
+	// Releasing the owner, as when its transcript is replaced, hands the key on
+	cache.release("a.jsonl")
+	if owned, repeat := cache.claim(hash, "b.jsonl"); !owned || repeat {
+		t.Errorf("claim after release = (%v, %v), want (true, false)", owned, repeat)
+	}
+}
+
+func TestOwnerIndexCompact(t *testing.T) {
+	index := &ownerIndex{
+		IDs:    map[string]uint32{},
+		Hashes: []uint64{10, 30, 50},
+		Owners: []uint32{1, 1, 2},
+		added:  map[uint64]uint32{5: 3, 40: 3, 60: 2},
+	}
+	index.compact()
+
+	wantHashes := []uint64{5, 10, 30, 40, 50, 60}
+	wantOwners := []uint32{3, 1, 1, 3, 2, 2}
+	for n := range wantHashes {
+		if index.Hashes[n] != wantHashes[n] || index.Owners[n] != wantOwners[n] {
+			t.Fatalf("compacted index = %v %v, want %v %v", index.Hashes, index.Owners, wantHashes, wantOwners)
+		}
+	}
+	for n, hash := range wantHashes {
+		if owner, ok := index.owner(hash); !ok || owner != wantOwners[n] {
+			t.Errorf("owner(%d) = %d, %v, want %d", hash, owner, ok, wantOwners[n])
+		}
+	}
+
+	index.release(1)
+	if _, ok := index.owner(10); ok {
+		t.Error("hash 10 still owned after releasing its owner")
+	}
+	if owner, ok := index.owner(40); !ok || owner != 3 {
+		t.Errorf("owner(40) = %d, %v after releasing another owner, want 3", owner, ok)
+	}
+}
+
+// renderUsage loads the account's usage the way a statusline render does,
+// with the transcript cache on disk.
+func renderUsage(t *testing.T) UsageTotals {
+	t.Helper()
+	cache := loadTranscriptCache()
+	history := loadUsageHistory(cache, "")
+	if err := cache.save(); err != nil {
+		t.Fatal(err)
+	}
+	return billedTotals(history.hourly)
+}
+
+func TestDeletedOwnerHandsOverCopiedUsage(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CLAUDE_CONFIG_DIR", "")
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	project := filepath.Join(home, ".claude", "projects", "-project")
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	original := filepath.Join(project, "original.jsonl")
+	for source, target := range map[string]string{
+		"testdata/dedup/session.jsonl": original,
+		"testdata/dedup/resumed.jsonl": filepath.Join(project, "resumed.jsonl"),
+	} {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all := UsageTotals{
+		InputTokens:         12 + 8 + 500 + 6,
+		OutputTokens:        340 + 120 + 40 + 75,
+		CacheReadTokens:     15000 + 17100 + 17500,
+		CacheCreationTokens: 2100 + 400,
+		Messages:            4,
+	}
+	if got := renderUsage(t); got != all {
+		t.Fatalf("usage = %+v, want %+v", got, all)
+	}
+
+	// Claude Code's cleanup deletes the original; the resumed session still
+	// holds copies of msg_01 and msg_02, which must now count there
+	if err := os.Remove(original); err != nil {
+		t.Fatal(err)
+	}
+	resumed := UsageTotals{
+		InputTokens:         12 + 8 + 6,
+		OutputTokens:        340 + 120 + 75,
+		CacheReadTokens:     15000 + 17100 + 17500,
+		CacheCreationTokens: 2100 + 400,
+		Messages:            3,
+	}
+	for render := range 2 {
+		if got := renderUsage(t); got != resumed {
+			t.Errorf("render %d after deleting the original: usage = %+v, want %+v", render+1, got, resumed)
+		}
+	}
+}
+
+// freshSummary parses data from scratch in a cache of its own.
+func freshSummary(t *testing.T, data []byte) *transcriptSummary {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fresh.jsonl")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := newTranscriptCache("").summarize(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+func checkSummary(t *testing.T, name string, got, want *transcriptSummary) {
+	t.Helper()
+	if !reflect.DeepEqual(got.Tokens, want.Tokens) {
+		t.Errorf("%s: tokens = %+v, want %+v", name, got.Tokens, want.Tokens)
+	}
+	if got, want := billedTotals(got.Hourly), billedTotals(want.Hourly); got != want {
+		t.Errorf("%s: hourly usage = %+v, want %+v", name, got, want)
+	}
+	if got.Offset != want.Offset {
+		t.Errorf("%s: offset = %d, want %d", name, got.Offset, want.Offset)
+	}
+}
+
+func TestSummarizeChangedTranscript(t *testing.T) {
+	session, err := os.ReadFile("testdata/dedup/session.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := os.ReadFile("testdata/dedup/resumed.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first five lines: msg_01 and the tool result after it
+	head := session[:bytes.Index(session, []byte(`"uuid": "a-2a"`))]
+	head = head[:bytes.LastIndexByte(head, '\n')+1]
+
+	write := func(path string, data []byte) {
+		t.Helper()
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	summarize := func(cache *transcriptCache, path string) *transcriptSummary {
+		t.Helper()
+		summary, err := cache.summarize(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return summary
+	}
+
+	t.Run("append", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "session.jsonl")
+		write(path, head)
+		cache := newTranscriptCache("")
+		summarize(cache, path)
+
+		// Blank out the lines already read: only appended bytes may be
+		// parsed, so the result still includes them
+		blanked := bytes.Map(func(r rune) rune {
+			if r == '\n' {
+				return r
+			}
+			return ' '
+		}, head)
+		write(path, append(blanked, session[len(head):]...))
+
+		checkSummary(t, "append", summarize(cache, path), freshSummary(t, session))
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "session.jsonl")
+		write(path, session)
+		cache := newTranscriptCache("")
+		summarize(cache, path)
+
+		if err := os.Truncate(path, int64(len(head))); err != nil {
+			t.Fatal(err)
+		}
+		checkSummary(t, "truncate", summarize(cache, path), freshSummary(t, head))
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "session.jsonl")
+		write(path, session)
+		cache := newTranscriptCache("")
+		summarize(cache, path)
+
+		// A new file renamed into place, longer than the old one so only
+		// its inode gives it away
+		replacement := append(bytes.Clone(resumed), head...)
+		write(filepath.Join(dir, "new.jsonl"), replacement)
+		if err := os.Rename(filepath.Join(dir, "new.jsonl"), path); err != nil {
+			t.Fatal(err)
+		}
+		checkSummary(t, "replace", summarize(cache, path), freshSummary(t, replacement))
+	})
+}
@@ -1,14 +1,12 @@
 package claude
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"log"
 	"os"
 	"path/filepath"
-	"sort"
-	"strings"
 	"time"
 )
 
@@ -91,270 +89,140 @@ func (c *ClaudeCode) getProjectName() string {
 	return ""
 }
 
-// TranscriptEntry represents a single entry in the session's transcript. Claude
-// Code transscripts are stored in JSONL format (newline delimited distinct JSON objects).
-type TranscriptEntry struct {
-	Timestamp   string   `json:"timestamp,omitempty"`
-	IsSidechain bool     `json:"isSidechain,omitempty"`
-	Message     *Message `json:"message,omitempty"`
-}
-
-type Message struct {
-	Usage *Usage `json:"usage,omitempty"`
-}
-
-type Usage struct {
-	InputTokens              int64 `json:"input_tokens,omitempty"`
-	OutputTokens             int64 `json:"output_tokens,omitempty"`
-	CacheReadInputTokens     int64 `json:"cache_read_input_tokens,omitempty"`
-	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens,omitempty"`
-}
-
 type ClaudeTokenMetrics struct {
-	InputTokens   int64 `json:"inputTokens"`
-	OutputTokens  int64 `json:"outputTokens"`
-	CachedTokens  int64 `json:"cachedTokens"`
-	TotalTokens   int64 `json:"totalTokens"`
-	ContextLength int64 `json:"contextLength"`
-}
-
-type ClaudeBlockMetrics struct {
-	StartTime    time.Time `json:"startTime"`
-	LastActivity time.Time `json:"lastActivity"`
+	InputTokens         int64 `json:"inputTokens"`
+	OutputTokens        int64 `json:"outputTokens"`
+	CacheReadTokens     int64 `json:"cacheReadTokens"`
+	CacheCreationTokens int64 `json:"cacheCreationTokens"`
+	TotalTokens         int64 `json:"totalTokens"`
+	ContextLength       int64 `json:"contextLength"`
+
+	// Models splits the session's usage by the model that produced it
+	Models ModelUsage `json:"models"`
+}
+
+// CacheHitRatio returns the fraction of prompt tokens that were read from the
+// prompt cache rather than processed as input or written to the cache.
+func (m *ClaudeTokenMetrics) CacheHitRatio() float64 {
+	promptTokens := m.InputTokens + m.CacheReadTokens + m.CacheCreationTokens
+	if promptTokens == 0 {
+		return 0
+	}
+	return float64(m.CacheReadTokens) / float64(promptTokens)
+}
+
+// CacheSavingsUSD returns how much cheaper the session's cache reads were
+// than sending the same tokens as uncached input, priced per model.
+func (m *ClaudeTokenMetrics) CacheSavingsUSD() float64 {
+	var savings float64
+	for model, usage := range m.Models {
+		if pricing, ok := Pricing().Lookup(model); ok {
+			savings += float64(usage.CacheReadTokens) * (pricing.Input - pricing.CacheRead) / 1e6
+		}
+	}
+	return savings
 }
 
-// SessionDuration represents the session duration in milliseconds (5 hours)
-const sessionDurationMs = int64(5 * 60 * 60 * 1000)
-
-func parseMetrics(transcriptPath string) (*ClaudeTokenMetrics, *ClaudeBlockMetrics, error) {
-	// Parses JSONL transcript file to extract token usage and session metrics
+func parseMetrics(cache *transcriptCache, transcriptPath string) (*ClaudeTokenMetrics, *ClaudeSubagentMetrics, error) {
+	// Parses JSONL transcript file to extract token usage and session metrics.
+	// Summaries are cached so each refresh only parses appended lines.
 
 	if transcriptPath == "" {
 		return nil, nil, nil
 	}
 
-	file, err := os.Open(transcriptPath)
+	summary, err := cache.summarize(transcriptPath)
 	if err != nil {
 		// Return nil metrics instead of failing - transcript may not exist yet
-		if !os.IsNotExist(err) {
-			log.Printf("Warning: failed to open transcript file %s: %v", transcriptPath, err)
-		}
-		return nil, nil, nil
-	}
-	defer file.Close()
-
-	var inputTokens, outputTokens, cachedTokens, contextLength int64
-	var mostRecentMainChainEntry *TranscriptEntry
-	var mostRecentTimestamp time.Time
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-
-		var entry TranscriptEntry
-		if err := json.Unmarshal([]byte(line), &entry); err != nil {
-			// Log parsing errors for debugging, but continue processing
-			log.Printf("Warning: failed to parse transcript line: %v", err)
-			continue
+		if os.IsNotExist(err) {
+			return nil, nil, nil
 		}
-
-		// Parse token usage data
-		if entry.Message != nil && entry.Message.Usage != nil {
-			usage := entry.Message.Usage
-			inputTokens += usage.InputTokens
-			outputTokens += usage.OutputTokens
-			cachedTokens += usage.CacheReadInputTokens + usage.CacheCreationInputTokens
-
-			// Track the most recent main chain entry for context length
-			// Main chain entries have isSidechain = false or undefined (defaults to main chain)
-			if !entry.IsSidechain && entry.Timestamp != "" {
-				if entryTime, err := time.Parse(time.RFC3339, entry.Timestamp); err == nil {
-					if mostRecentTimestamp.IsZero() || entryTime.After(mostRecentTimestamp) {
-						mostRecentTimestamp = entryTime
-						mostRecentMainChainEntry = &entry
-					}
-				} else {
-					log.Printf("Warning: failed to parse timestamp %s: %v", entry.Timestamp, err)
-				}
-			}
+		err = fmt.Errorf("failed to read transcript file %s: %w", transcriptPath, err)
+		if summary == nil {
+			return nil, nil, err
 		}
 	}
 
-	// Calculate context length from the most recent main chain message
-	if mostRecentMainChainEntry != nil && mostRecentMainChainEntry.Message != nil && mostRecentMainChainEntry.Message.Usage != nil {
-		usage := mostRecentMainChainEntry.Message.Usage
-		contextLength = usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens
-	}
-
-	totalTokens := inputTokens + outputTokens + cachedTokens
-
-	tokenMetrics := &ClaudeTokenMetrics{
-		InputTokens:   inputTokens,
-		OutputTokens:  outputTokens,
-		CachedTokens:  cachedTokens,
-		TotalTokens:   totalTokens,
-		ContextLength: contextLength,
-	}
-
-	// Parse block metrics from the same file to avoid duplicate I/O
-	blockMetrics, err := parseBlockMetricsFromFile(file)
-	if err != nil {
-		log.Printf("Warning: failed to parse block metrics: %v", err)
-		blockMetrics = nil
-	}
-
-	return tokenMetrics, blockMetrics, nil
-}
-
-// parseBlockMetricsFromFile parses block metrics from an already open file
-func parseBlockMetricsFromFile(file *os.File) (*ClaudeBlockMetrics, error) {
-	// Reset file pointer to beginning
-	if _, err := file.Seek(0, 0); err != nil {
-		return nil, fmt.Errorf("failed to seek to start of file: %v", err)
+	// After a read error, the metrics of the lines read so far are returned
+	// along with it
+	tokenMetrics := summary.Tokens
+	subagentMetrics := summary.Subagents
+	return &tokenMetrics, &subagentMetrics, err
+}
+
+// apply folds a single transcript entry into the summary. claim records the
+// transcript as containing a usage key hash and reports whether it is the one
+// that should count it in its buckets, and whether it had already claimed it.
+func (s *transcriptSummary) apply(entry *usageRecord, claim func(hash uint64) (owned, repeat bool)) {
+	var entryTime time.Time
+	if entry.Timestamp != "" {
+		t, err := time.Parse(time.RFC3339, entry.Timestamp)
+		if err != nil {
+			log.Printf("Warning: failed to parse timestamp %s: %v", entry.Timestamp, err)
+		} else {
+			entryTime = t
+			s.Runs = addActivity(s.Runs, t)
+		}
 	}
 
-	timestamps, err := extractTimestamps(file)
-	if err != nil {
-		return nil, err
-	}
+	s.Subagents.track(entry, entryTime)
 
-	if len(timestamps) == 0 {
-		return nil, nil
+	// Parse token usage data
+	if entry.Message == nil || entry.Message.Usage == nil {
+		return
 	}
 
-	return calculateBlockMetrics(timestamps), nil
-}
-
-// extractTimestamps efficiently extracts and sorts timestamps from transcript
-func extractTimestamps(file *os.File) ([]time.Time, error) {
-	var timestamps []time.Time
-	scanner := bufio.NewScanner(file)
+	usage := entry.Message.Usage
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
+	// Context length comes from the most recent main chain message
+	// Main chain entries have isSidechain = false or undefined (defaults to main chain)
+	if !entry.IsSidechain && !entryTime.IsZero() {
+		if s.ContextTimestamp.IsZero() || entryTime.After(s.ContextTimestamp) {
+			s.ContextTimestamp = entryTime
+			s.Tokens.ContextLength = usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens
 		}
+	}
 
-		var entry TranscriptEntry
-		if err := json.Unmarshal([]byte(line), &entry); err != nil {
-			continue // Skip invalid JSON lines
+	// Count each API response once, however many lines repeat its usage
+	owned := true
+	if key := entry.usageKey(); key != "" {
+		hash := fnv.New64a()
+		hash.Write([]byte(key))
+		if s.Copied[hash.Sum64()] {
+			return
 		}
-
-		if entry.Timestamp != "" {
-			if t, err := time.Parse(time.RFC3339, entry.Timestamp); err == nil {
-				timestamps = append(timestamps, t)
-			}
+		var repeat bool
+		owned, repeat = claim(hash.Sum64())
+		if repeat {
+			return
 		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error scanning file: %v", err)
-	}
-
-	// Use efficient sort instead of bubble sort
-	sort.Slice(timestamps, func(i, j int) bool {
-		return timestamps[i].Before(timestamps[j])
-	})
-
-	return timestamps, nil
-}
-
-// calculateBlockMetrics computes block metrics from sorted timestamps
-func calculateBlockMetrics(timestamps []time.Time) *ClaudeBlockMetrics {
-	if len(timestamps) == 0 {
-		return nil
-	}
-
-	now := time.Now()
-	mostRecentTimestamp := timestamps[len(timestamps)-1]
-
-	// Check if the most recent activity is within the current session period
-	if now.Sub(mostRecentTimestamp).Milliseconds() > sessionDurationMs {
-		return nil // No recent activity
-	}
-
-	// Find the start of the current continuous work period
-	continuousWorkStart := findContinuousWorkStart(timestamps, sessionDurationMs)
-
-	// Floor to the hour
-	flooredWorkStart := floorToHour(continuousWorkStart)
-
-	// Calculate current block within the work period
-	blockStart := calculateBlockStart(now, flooredWorkStart, sessionDurationMs)
-
-	return &ClaudeBlockMetrics{
-		StartTime:    blockStart,
-		LastActivity: mostRecentTimestamp,
-	}
-}
-
-// findContinuousWorkStart finds the start of continuous work period
-func findContinuousWorkStart(timestamps []time.Time, sessionDurationMs int64) time.Time {
-	if len(timestamps) == 0 {
-		return time.Time{}
-	}
-
-	continuousWorkStart := timestamps[len(timestamps)-1]
-	for i := len(timestamps) - 2; i >= 0; i-- {
-		gap := timestamps[i+1].Sub(timestamps[i]).Milliseconds()
-		if gap >= sessionDurationMs {
-			break // Found a session boundary
+		if !owned {
+			s.Copied[hash.Sum64()] = true
 		}
-		continuousWorkStart = timestamps[i]
 	}
-	return continuousWorkStart
-}
 
-// floorToHour floors a timestamp to the hour boundary
-func floorToHour(t time.Time) time.Time {
-	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
-}
-
-// calculateBlockStart determines the start time of the current block
-func calculateBlockStart(now, flooredWorkStart time.Time, sessionDurationMs int64) time.Time {
-	totalWorkTime := now.Sub(flooredWorkStart).Milliseconds()
-	if totalWorkTime > sessionDurationMs {
-		completedBlocks := totalWorkTime / sessionDurationMs
-		blockStartMs := flooredWorkStart.UnixMilli() + (completedBlocks * sessionDurationMs)
-		return time.UnixMilli(blockStartMs)
+	if owned && !entryTime.IsZero() {
+		s.Hourly.addUsage(hourKey(entryTime), entry.Message.Model, usage)
+		s.Recent.addUsage(minuteKey(entryTime), entry.Message.Model, usage)
 	}
-	return flooredWorkStart
-}
 
-// getBlockMetrics maintains backward compatibility by wrapping the new implementation
-func getBlockMetrics(transcriptPath string) *ClaudeBlockMetrics {
-	if transcriptPath == "" {
-		return nil
-	}
-
-	file, err := os.Open(transcriptPath)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			log.Printf("Warning: failed to open transcript file %s: %v", transcriptPath, err)
-		}
-		return nil
-	}
-	defer file.Close()
+	s.Tokens.InputTokens += usage.InputTokens
+	s.Tokens.OutputTokens += usage.OutputTokens
+	s.Tokens.CacheReadTokens += usage.CacheReadInputTokens
+	s.Tokens.CacheCreationTokens += usage.CacheCreationInputTokens
+	s.Tokens.TotalTokens = s.Tokens.InputTokens + s.Tokens.OutputTokens + s.Tokens.CacheReadTokens + s.Tokens.CacheCreationTokens
+	s.Tokens.Models.addUsage(entry.Message.Model, usage)
 
-	blockMetrics, err := parseBlockMetricsFromFile(file)
-	if err != nil {
-		log.Printf("Warning: failed to parse block metrics from %s: %v", transcriptPath, err)
-		return nil
+	if entry.IsSidechain {
+		s.Subagents.Usage.addUsage(entry.Message.Model, usage)
 	}
-
-	return blockMetrics
 }
 
-func formatDuration(hours, minutes int) string {
-	if hours == 0 {
-		return fmt.Sprintf("%dm", minutes)
-	} else if minutes == 0 {
-		return fmt.Sprintf("%dhr", hours)
-	} else {
-		return fmt.Sprintf("%dhr %dm", hours, minutes)
-	}
+// pruneRecent drops per-minute usage older than a block before now. Only the
+// current block's burn rate uses it, so transcripts idle for longer keep none.
+// It reports whether anything was dropped.
+func (s *transcriptSummary) pruneRecent(now time.Time) bool {
+	before := len(s.Recent)
+	s.Recent.prune(minuteKey(now) - recentRetentionMinutes)
+	return len(s.Recent) != before
 }
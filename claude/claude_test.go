@@ -0,0 +1,62 @@
+package claude
+
+import (
+	"math"
+	"testing"
+)
+
+// billedTotals sums every bucket, i.e. what the account was billed for.
+func billedTotals(buckets usageBuckets) UsageTotals {
+	usage := buckets.between(math.MinInt64, math.MaxInt64)
+	return usage.Totals()
+}
+
+func TestParseMetricsDeduplicatesUsage(t *testing.T) {
+	cache := newTranscriptCache("")
+	tokens, subagents, err := parseMetrics(cache, "testdata/dedup/session.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// msg_01 appears on three lines and msg_02 on two; each is billed once
+	want := ClaudeTokenMetrics{
+		InputTokens:         12 + 8 + 500,
+		OutputTokens:        340 + 120 + 40,
+		CacheReadTokens:     15000 + 17100,
+		CacheCreationTokens: 2100 + 400,
+		TotalTokens:         520 + 500 + 32100 + 2500,
+		// From msg_02, the last main chain response
+		ContextLength: 8 + 17100 + 400,
+	}
+	if tokens.InputTokens != want.InputTokens || tokens.OutputTokens != want.OutputTokens ||
+		tokens.CacheReadTokens != want.CacheReadTokens || tokens.CacheCreationTokens != want.CacheCreationTokens ||
+		tokens.TotalTokens != want.TotalTokens || tokens.ContextLength != want.ContextLength {
+		t.Errorf("token metrics = %+v, want %+v", *tokens, want)
+	}
+
+	if got := tokens.Models["claude-sonnet-4-5-20250929"].Messages; got != 2 {
+		t.Errorf("sonnet messages = %d, want 2", got)
+	}
+	if got := tokens.Models["claude-3-5-haiku-20241022"].Messages; got != 1 {
+		t.Errorf("haiku messages = %d, want 1", got)
+	}
+	if got := subagents.Usage.Totals(); got.Messages != 1 || got.InputTokens != 500 {
+		t.Errorf("subagent usage = %+v, want the single haiku response", got)
+	}
+}
+
+func TestParseMetricsIsIncremental(t *testing.T) {
+	cache := newTranscriptCache("")
+	first, _, err := parseMetrics(cache, "testdata/dedup/session.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Unchanged transcripts are served from the summary without double counting
+	second, _, err := parseMetrics(cache, "testdata/dedup/session.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.TotalTokens != second.TotalTokens {
+		t.Errorf("total tokens changed from %d to %d on an unchanged transcript", first.TotalTokens, second.TotalTokens)
+	}
+}
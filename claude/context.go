@@ -3,14 +3,21 @@ package claude
 import (
 	"fmt"
 	"io"
+	"log"
+	"time"
 )
 
 type Context struct {
-	Code         *ClaudeCode
-	TokenMetrics *ClaudeTokenMetrics
-	BlockMetrics *ClaudeBlockMetrics
-	WorkingDir   string
-	ProjectName  string
+	Code            *ClaudeCode
+	TokenMetrics    *ClaudeTokenMetrics
+	SubagentMetrics *ClaudeSubagentMetrics
+	BlockMetrics    *ClaudeBlockMetrics
+	Usage           *UsageHistory
+	WorkingDir      string
+	ProjectName     string
+	// Now is the time the context describes: when it was built, or when the
+	// input was recorded for a replay
+	Now time.Time
 }
 
 func NewContextFromReader(r io.Reader) (*Context, error) {
@@ -23,25 +30,63 @@ func NewContextFromReader(r io.Reader) (*Context, error) {
 		return nil, fmt.Errorf("no input received")
 	}
 
+	cache := loadTranscriptCache()
+	claudeContext, err := newContext(jsonData, cache)
+
+	// Save even after a transcript read error so the lines parsed so far are
+	// kept
+	if err := cache.save(); err != nil {
+		log.Printf("Warning: failed to save transcript cache: %v", err)
+	}
+	return claudeContext, err
+}
+
+// newContext builds the context for statusline input from the transcripts
+// as cache sees them at cache.now.
+func newContext(jsonData []byte, cache *transcriptCache) (*Context, error) {
 	code, err := unmarshalClaudeCodeInput(jsonData)
 	if err != nil {
 		return nil, fmt.Errorf("invalid JSON: %w", err)
 	}
 
-	var tokenMetrics *ClaudeTokenMetrics
-	var blockMetrics *ClaudeBlockMetrics
-	if code.TranscriptPath != "" {
-		tokenMetrics, blockMetrics, err = parseMetrics(code.TranscriptPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse metrics: %w", err)
-		}
+	// A transcript that cannot be read in full still leaves the metrics of
+	// the lines read so far, which beats no statusline at all
+	tokenMetrics, subagentMetrics, err := parseMetrics(cache, code.TranscriptPath)
+	if err != nil {
+		log.Printf("Warning: failed to parse metrics: %v", err)
 	}
+	usage := loadUsageHistory(cache, code.TranscriptPath)
 
 	return &Context{
-		Code:         code,
-		TokenMetrics: tokenMetrics,
-		BlockMetrics: blockMetrics,
-		WorkingDir:   code.getWorkingDir(),
-		ProjectName:  code.getProjectName(),
+		Code:            code,
+		TokenMetrics:    tokenMetrics,
+		SubagentMetrics: subagentMetrics,
+		BlockMetrics:    usage.CurrentBlock(cache.now),
+		Usage:           usage,
+		WorkingDir:      code.getWorkingDir(),
+		ProjectName:     code.getProjectName(),
+		Now:             cache.now,
 	}, nil
 }
+
+// Replay builds contexts for recorded statusline input as of when it was
+// recorded. Transcripts are only read up to that time, and the transcript
+// cache on disk is neither used nor updated.
+type Replay struct {
+	cache *transcriptCache
+}
+
+func NewReplay() *Replay {
+	return &Replay{}
+}
+
+// Context returns the context for input recorded at the given time. Inputs
+// are expected in the order they were recorded, so each only parses the
+// lines written since the previous one; going back in time starts over.
+func (r *Replay) Context(input []byte, at time.Time) (*Context, error) {
+	if r.cache == nil || at.Before(r.cache.until) {
+		r.cache = newTranscriptCache("")
+	}
+	r.cache.now, r.cache.until = at, at
+	return newContext(input, r.cache)
+}
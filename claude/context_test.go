@@ -0,0 +1,89 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplayReadsTranscriptsAsRecorded(t *testing.T) {
+	home := t.TempDir()
+	cacheDir := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CLAUDE_CONFIG_DIR", "")
+	t.Setenv("XDG_CACHE_HOME", cacheDir)
+
+	data, err := os.ReadFile("testdata/dedup/session.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcript := filepath.Join(home, ".claude", "projects", "-project", "s1.jsonl")
+	if err := os.MkdirAll(filepath.Dir(transcript), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(transcript, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	input := []byte(fmt.Sprintf(`{"session_id": "s1", "transcript_path": %q}`, transcript))
+
+	replay := NewReplay()
+	steps := []struct {
+		at     string
+		output int64
+	}{
+		{"2025-06-02T10:00:08Z", 340},
+		{"2025-06-02T10:00:10Z", 340 + 120},
+		{"2025-06-02T11:00:00Z", 340 + 120 + 40},
+		// Going back in time starts over
+		{"2025-06-02T10:00:08Z", 340},
+	}
+	for _, step := range steps {
+		at, _ := time.Parse(time.RFC3339, step.at)
+		claudeContext, err := replay.Context(input, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := claudeContext.TokenMetrics.OutputTokens; got != step.output {
+			t.Errorf("output tokens at %s = %d, want %d", step.at, got, step.output)
+		}
+		if !claudeContext.Now.Equal(at) {
+			t.Errorf("context time = %v, want %v", claudeContext.Now, at)
+		}
+		// The block is long over by now, but active when the entry was recorded
+		if block := claudeContext.BlockMetrics; block == nil || block.Usage.OutputTokens != step.output {
+			t.Errorf("block at %s = %+v, want one with %d output tokens", step.at, block, step.output)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(cacheDir, "cstatus")); !os.IsNotExist(err) {
+		t.Errorf("replay wrote to the transcript cache: %v", err)
+	}
+}
+
+func TestContextSurvivesTranscriptReadError(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CLAUDE_CONFIG_DIR", "")
+
+	// Opening a directory succeeds, reading it fails
+	transcript := filepath.Join(home, "transcript.jsonl")
+	if err := os.Mkdir(transcript, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	input := []byte(fmt.Sprintf(`{"session_id": "s1", "transcript_path": %q}`, transcript))
+
+	cache := newTranscriptCache("")
+	if _, _, err := parseMetrics(cache, transcript); err == nil {
+		t.Fatal("parseMetrics read a directory without error")
+	}
+
+	claudeContext, err := newContext(input, cache)
+	if err != nil {
+		t.Fatalf("newContext failed on an unreadable transcript: %v", err)
+	}
+	if claudeContext.TokenMetrics == nil || claudeContext.TokenMetrics.TotalTokens != 0 {
+		t.Errorf("token metrics = %+v, want the empty metrics read so far", claudeContext.TokenMetrics)
+	}
+}
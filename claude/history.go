@@ -0,0 +1,117 @@
+package claude
+
+import (
+	"iter"
+	"log"
+	"maps"
+	"os"
+	"time"
+)
+
+// UsageHistory is the usage recorded in every transcript on the machine.
+// Usage limits apply to the whole account, so blocks are computed from all
+// sessions rather than only the one rendering the statusline.
+type UsageHistory struct {
+	runs   []activityRun
+	hourly usageBuckets
+	recent usageBuckets
+
+	// limitKeys are the hourly keys in order and limitSums the LimitTokens
+	// used before each of them, built on first use by MaxBlockLimitTokens
+	limitKeys []int64
+	limitSums []int64
+}
+
+// LoadUsageHistory reads the usage history from every transcript, for use
+// outside of a statusline render.
+func LoadUsageHistory() *UsageHistory {
+	cache := loadTranscriptCache()
+	history := loadUsageHistory(cache, "")
+	if err := cache.save(); err != nil {
+		log.Printf("Warning: failed to save transcript cache: %v", err)
+	}
+	return history
+}
+
+func loadUsageHistory(cache *transcriptCache, currentPath string) *UsageHistory {
+	files := findTranscripts(transcriptRoots(), currentPath)
+	cache.prune(files)
+	// Every transcript holding a copy of these is visited below
+	orphans := maps.Clone(cache.orphanSet())
+
+	history := &UsageHistory{
+		hourly: usageBuckets{},
+		recent: usageBuckets{},
+	}
+	for _, file := range files {
+		summary, err := cache.summarize(file.Path)
+		// The current transcript's errors are reported with its metrics
+		if err != nil && !os.IsNotExist(err) && file.Path != currentPath {
+			log.Printf("Warning: failed to read transcript file %s: %v", file.Path, err)
+		}
+		if summary == nil {
+			continue
+		}
+
+		for _, run := range summary.Runs {
+			history.runs = addRun(history.runs, run)
+		}
+		history.hourly.merge(summary.Hourly)
+		history.recent.merge(summary.Recent)
+	}
+	cache.dropOrphans(orphans)
+	return history
+}
+
+// Between returns the usage from from up to to. Usage is bucketed by hour, so
+// both ends are effectively floored to the hour.
+func (h *UsageHistory) Between(from, to time.Time) ModelUsage {
+	return h.hourly.between(hourKey(from), hourKey(to))
+}
+
+// CurrentBlock returns the block active at now, or nil if there is none.
+func (h *UsageHistory) CurrentBlock(now time.Time) *ClaudeBlockMetrics {
+	block := calculateBlockMetrics(h.runs, now)
+	if block != nil {
+		block.aggregate(h, now)
+	}
+	return block
+}
+
+// Blocks returns every block with recorded usage, oldest first. Each period
+// of continuous work is split into consecutive blocks starting at its first
+// activity floored to the hour, as for the current block.
+func (h *UsageHistory) Blocks(now time.Time) []*ClaudeBlockMetrics {
+	var blocks []*ClaudeBlockMetrics
+	for block := range h.blockSpans() {
+		block.aggregate(h, now)
+		if block.Usage.Messages > 0 {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// blockSpans yields the start, end and last activity of every block, oldest
+// first, without their usage.
+func (h *UsageHistory) blockSpans() iter.Seq[*ClaudeBlockMetrics] {
+	length := time.Duration(sessionDurationMs) * time.Millisecond
+
+	return func(yield func(*ClaudeBlockMetrics) bool) {
+		for _, run := range h.runs {
+			for start := floorToHour(run.Start); !start.After(run.End); start = start.Add(length) {
+				block := &ClaudeBlockMetrics{
+					StartTime:    start,
+					EndTime:      start.Add(length),
+					LastActivity: run.End,
+				}
+				if block.EndTime.Before(run.End) {
+					block.LastActivity = block.EndTime
+				}
+				if !yield(block) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package claude
+
+import "os"
+
+// fileInode is unavailable on this platform; replacement is detected by size
+// and modification time alone.
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
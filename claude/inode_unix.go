@@ -0,0 +1,17 @@
+//go:build unix
+
+package claude
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns the inode number of a file, used to detect transcripts
+// that were replaced rather than appended to.
+func fileInode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
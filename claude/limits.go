@@ -0,0 +1,51 @@
+package claude
+
+import (
+	"slices"
+	"time"
+)
+
+// PlanTokenLimits are approximate per-block limits, in LimitTokens, for each
+// subscription plan. Anthropic does not publish exact numbers; these match
+// what community tools have observed.
+var PlanTokenLimits = map[string]int64{
+	"pro":   19000,
+	"max5":  88000,
+	"max20": 220000,
+}
+
+// MaxBlockLimitTokens returns the most LimitTokens used in any block that
+// ended before now, or 0 if there is none. Heavy users regularly run into the
+// limit, so this approximates their real per-block limit.
+func (h *UsageHistory) MaxBlockLimitTokens(now time.Time) int64 {
+	if h.limitSums == nil {
+		h.indexLimitTokens()
+	}
+
+	var maxTokens int64
+	for block := range h.blockSpans() {
+		if block.EndTime.After(now) {
+			break
+		}
+		from, _ := slices.BinarySearch(h.limitKeys, hourKey(block.StartTime))
+		to, _ := slices.BinarySearch(h.limitKeys, hourKey(block.EndTime))
+		maxTokens = max(maxTokens, h.limitSums[to]-h.limitSums[from])
+	}
+	return maxTokens
+}
+
+// indexLimitTokens sums LimitTokens over the hourly buckets in order, so the
+// usage of any block is the difference of two sums.
+func (h *UsageHistory) indexLimitTokens() {
+	h.limitKeys = make([]int64, 0, len(h.hourly))
+	for key := range h.hourly {
+		h.limitKeys = append(h.limitKeys, key)
+	}
+	slices.Sort(h.limitKeys)
+
+	h.limitSums = make([]int64, len(h.limitKeys)+1)
+	for n, key := range h.limitKeys {
+		totals := h.hourly[key].Totals()
+		h.limitSums[n+1] = h.limitSums[n] + totals.LimitTokens()
+	}
+}
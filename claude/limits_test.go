@@ -0,0 +1,64 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// randomHistory builds a history of bursts of work separated by gaps both
+// shorter and longer than a block.
+func randomHistory(rng *rand.Rand) *UsageHistory {
+	cache := newTranscriptCache("")
+	summary := &transcriptSummary{}
+	summary.initMaps()
+
+	at := time.Date(2025, 6, 1, 8, 17, 0, 0, time.UTC)
+	for n := range 400 {
+		at = at.Add(time.Duration(rng.IntN(90)) * time.Minute)
+		if rng.IntN(10) == 0 {
+			at = at.Add(time.Duration(5+rng.IntN(20)) * time.Hour)
+		}
+		line := fmt.Sprintf(`{"uuid": "a-%d", "timestamp": %q, "message": {"id": "msg_%d",
+			"model": "claude-sonnet-4-5-20250929", "usage": {"input_tokens": %d, "output_tokens": %d}}}`,
+			n, at.Format(time.RFC3339), n, rng.IntN(5000), rng.IntN(20000))
+		var record usageRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			panic(err)
+		}
+		summary.apply(&record, func(hash uint64) (bool, bool) {
+			return cache.claim(hash, "random.jsonl")
+		})
+	}
+
+	history := &UsageHistory{hourly: summary.Hourly, recent: summary.Recent}
+	for _, run := range summary.Runs {
+		history.runs = addRun(history.runs, run)
+	}
+	return history
+}
+
+func TestMaxBlockLimitTokens(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for range 20 {
+		history := randomHistory(rng)
+		end := history.runs[len(history.runs)-1].End
+
+		for _, now := range []time.Time{end.Add(-30 * time.Hour), end, end.Add(6 * time.Hour)} {
+			var want int64
+			for _, block := range history.Blocks(now) {
+				if !block.EndTime.After(now) {
+					want = max(want, block.Usage.LimitTokens())
+				}
+			}
+			if want == 0 && now.After(end) {
+				t.Fatal("history has no finished blocks")
+			}
+			if got := history.MaxBlockLimitTokens(now); got != want {
+				t.Errorf("MaxBlockLimitTokens(%v) = %d, want %d", now, got, want)
+			}
+		}
+	}
+}
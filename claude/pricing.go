@@ -0,0 +1,146 @@
+package claude
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// pricingJSON is the built-in pricing table. Bump its version whenever
+// prices change.
+//
+//go:embed pricing.json
+var pricingJSON []byte
+
+// PricingTable lists model prices keyed by model ID. A model is priced by the
+// longest key that is a prefix of its ID, so "claude-sonnet-4-5" covers
+// dated IDs such as "claude-sonnet-4-5-20250929".
+type PricingTable struct {
+	Version string                  `json:"version"`
+	Models  map[string]ModelPricing `json:"models"`
+}
+
+// ModelPricing holds token prices in USD per million tokens, and the web
+// search price in USD per thousand searches.
+type ModelPricing struct {
+	Input        float64 `json:"input"`
+	Output       float64 `json:"output"`
+	CacheWrite5m float64 `json:"cache_write_5m"`
+	CacheWrite1h float64 `json:"cache_write_1h"`
+	CacheRead    float64 `json:"cache_read"`
+	WebSearch    float64 `json:"web_search"`
+}
+
+var (
+	pricingOnce  sync.Once
+	pricingTable *PricingTable
+)
+
+// PricingOverridePath returns the local pricing file whose models replace
+// or extend the built-in table: CSTATUS_PRICING if set, otherwise
+// ~/.claude/cstatus-pricing.json.
+func PricingOverridePath() string {
+	if path := os.Getenv("CSTATUS_PRICING"); path != "" {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".claude", "cstatus-pricing.json")
+}
+
+// Pricing returns the pricing table in effect, loading it on first use.
+func Pricing() *PricingTable {
+	pricingOnce.Do(func() {
+		pricingTable = &PricingTable{}
+		if err := json.Unmarshal(pricingJSON, pricingTable); err != nil {
+			panic(fmt.Sprintf("invalid built-in pricing table: %v", err))
+		}
+
+		path := PricingOverridePath()
+		if path == "" {
+			return
+		}
+		if err := pricingTable.overlay(path); err != nil {
+			log.Printf("Warning: ignoring pricing override: %v", err)
+		}
+	})
+	return pricingTable
+}
+
+func (p *PricingTable) overlay(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("could not read %s: %w", path, err)
+	}
+
+	var override PricingTable
+	if err := json.Unmarshal(data, &override); err != nil {
+		return fmt.Errorf("could not parse %s: %w", path, err)
+	}
+
+	for model, pricing := range override.Models {
+		p.Models[model] = pricing
+	}
+	if override.Version != "" {
+		p.Version = p.Version + "+" + override.Version
+	}
+	return nil
+}
+
+// Lookup returns the pricing for a model ID.
+func (p *PricingTable) Lookup(model string) (ModelPricing, bool) {
+	if pricing, ok := p.Models[model]; ok {
+		return pricing, true
+	}
+
+	best := ""
+	for key := range p.Models {
+		if strings.HasPrefix(model, key) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return ModelPricing{}, false
+	}
+	return p.Models[best], true
+}
+
+// Price returns the cost in USD of usage totals for a model.
+func (m ModelPricing) Price(usage *UsageTotals) float64 {
+	cacheWrite5m := usage.CacheCreationTokens - usage.CacheCreation1hTokens
+
+	tokenCost := float64(usage.InputTokens)*m.Input +
+		float64(usage.OutputTokens)*m.Output +
+		float64(cacheWrite5m)*m.CacheWrite5m +
+		float64(usage.CacheCreation1hTokens)*m.CacheWrite1h +
+		float64(usage.CacheReadTokens)*m.CacheRead
+	searchCost := float64(usage.WebSearchRequests) * m.WebSearch
+
+	return tokenCost/1e6 + searchCost/1e3
+}
+
+// PriceUsage returns the cost in USD of a single API response's usage.
+// Unknown models cost nothing.
+func PriceUsage(model string, usage *Usage) float64 {
+	var totals UsageTotals
+	totals.addUsage(usage)
+	return priceTotals(model, &totals)
+}
+
+func priceTotals(model string, usage *UsageTotals) float64 {
+	pricing, ok := Pricing().Lookup(model)
+	if !ok {
+		return 0
+	}
+	return pricing.Price(usage)
+}
@@ -0,0 +1,172 @@
+package claude
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Price with the built-in table only, whatever overrides the user has
+	os.Setenv("CSTATUS_PRICING", filepath.Join(os.TempDir(), "cstatus-no-pricing-override.json"))
+	os.Exit(m.Run())
+}
+
+func TestCostMatchesReportedTotal(t *testing.T) {
+	// Each fixture pairs a transcript with the statusline input Claude Code
+	// sent for it, including the session's total_cost_usd. See
+	// testdata/cost/README.md for how they are captured.
+	inputs, err := filepath.Glob(filepath.Join("testdata", "cost", "*.json"))
+	if err != nil || len(inputs) == 0 {
+		t.Fatalf("no cost fixtures: %v", err)
+	}
+	for _, input := range inputs {
+		t.Run(strings.TrimSuffix(filepath.Base(input), ".json"), func(t *testing.T) {
+			data, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := unmarshalClaudeCodeInput(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tokens, _, err := parseMetrics(newTranscriptCache(""), code.TranscriptPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, want := tokens.Models.CostUSD(), code.Cost.TotalCostUSD
+			if math.Abs(got-want) > want*0.001 {
+				t.Errorf("cost = $%.6f, want $%.6f", got, want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	table := &PricingTable{Models: map[string]ModelPricing{
+		"claude-opus-4":   {Input: 15},
+		"claude-opus-4-5": {Input: 5},
+		"claude-sonnet-4": {Input: 3},
+	}}
+
+	tests := []struct {
+		model string
+		input float64
+		ok    bool
+	}{
+		{"claude-opus-4", 15, true},
+		{"claude-opus-4-20250514", 15, true},
+		// The longest matching prefix wins
+		{"claude-opus-4-5-20251101", 5, true},
+		{"claude-sonnet-4-5-20250929", 3, true},
+		{"claude-haiku-4-5", 0, false},
+		{"opus-4", 0, false},
+	}
+	for _, test := range tests {
+		pricing, ok := table.Lookup(test.model)
+		if ok != test.ok || pricing.Input != test.input {
+			t.Errorf("Lookup(%q) = %v, %v, want input %v, %v", test.model, pricing.Input, ok, test.input, test.ok)
+		}
+	}
+}
+
+func TestPricingOverlay(t *testing.T) {
+	table := &PricingTable{Version: "2026-10-01", Models: map[string]ModelPricing{
+		"claude-opus-4":   {Input: 15, Output: 75},
+		"claude-sonnet-4": {Input: 3, Output: 15},
+	}}
+
+	path := filepath.Join(t.TempDir(), "cstatus-pricing.json")
+	override := `{"version": "local", "models": {
+		"claude-opus-4": {"input": 12, "output": 60},
+		"claude-opus-4-9": {"input": 4, "output": 20}
+	}}`
+	if err := os.WriteFile(path, []byte(override), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.overlay(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if table.Version != "2026-10-01+local" {
+		t.Errorf("version = %q, want %q", table.Version, "2026-10-01+local")
+	}
+	// Overridden models are replaced, new ones added and the rest kept
+	for model, want := range map[string]float64{
+		"claude-opus-4-20250514":     12,
+		"claude-opus-4-9-20270101":   4,
+		"claude-sonnet-4-5-20250929": 3,
+	} {
+		if pricing, _ := table.Lookup(model); pricing.Input != want {
+			t.Errorf("input price of %s = %v, want %v", model, pricing.Input, want)
+		}
+	}
+
+	if err := table.overlay(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("missing override: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := table.overlay(path); err == nil {
+		t.Error("invalid override parsed without error")
+	}
+}
+
+func TestPriceUsage(t *testing.T) {
+	responses := []struct {
+		model string
+		usage Usage
+	}{
+		{"claude-sonnet-4-5-20250929", Usage{
+			InputTokens:              9,
+			OutputTokens:             1480,
+			CacheReadInputTokens:     18200,
+			CacheCreationInputTokens: 3350,
+			ServerToolUse:            &ServerToolUse{WebSearchRequests: 2},
+		}},
+		{"claude-sonnet-4-5-20250929", Usage{
+			InputTokens:              4,
+			OutputTokens:             512,
+			CacheCreationInputTokens: 18200,
+			CacheCreation:            &CacheCreation{Ephemeral1hInputTokens: 18200},
+		}},
+		{"claude-3-5-haiku-20241022", Usage{InputTokens: 1800, OutputTokens: 95}},
+		{"unknown-model", Usage{InputTokens: 1000}},
+	}
+
+	// Pricing responses one at a time adds up to pricing their totals
+	var sum float64
+	usage := ModelUsage{}
+	for _, response := range responses {
+		sum += PriceUsage(response.model, &response.usage)
+		usage.addUsage(response.model, &response.usage)
+	}
+	if want := usage.CostUSD(); math.Abs(sum-want) > 1e-9 {
+		t.Errorf("sum of response costs = %v, want %v", sum, want)
+	}
+
+	if cost := PriceUsage("unknown-model", &Usage{InputTokens: 1000}); cost != 0 {
+		t.Errorf("unknown model cost = %v, want 0", cost)
+	}
+}
+
+func TestPrice(t *testing.T) {
+	pricing := ModelPricing{Input: 3, Output: 15, CacheWrite5m: 3.75, CacheWrite1h: 6, CacheRead: 0.3, WebSearch: 10}
+	usage := UsageTotals{
+		InputTokens:           1_000_000,
+		OutputTokens:          100_000,
+		CacheCreationTokens:   300_000,
+		CacheCreation1hTokens: 100_000,
+		CacheReadTokens:       2_000_000,
+		WebSearchRequests:     5,
+	}
+	// 3 + 1.5 + 0.75 + 0.6 + 0.6 + 0.05
+	if got, want := pricing.Price(&usage), 6.5; math.Abs(got-want) > 1e-9 {
+		t.Errorf("price = %v, want %v", got, want)
+	}
+}
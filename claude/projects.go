@@ -0,0 +1,77 @@
+package claude
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// transcriptFile is a transcript found on disk.
+type transcriptFile struct {
+	Path    string
+	ModTime time.Time
+}
+
+// transcriptRoots returns the Claude config directories whose projects folder
+// holds session transcripts: ~/.claude, plus any directories listed
+// (comma separated) in CLAUDE_CONFIG_DIR.
+func transcriptRoots() []string {
+	var roots []string
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		roots = append(roots, filepath.Join(homeDir, ".claude"))
+	}
+	for _, dir := range strings.Split(os.Getenv("CLAUDE_CONFIG_DIR"), ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			roots = append(roots, dir)
+		}
+	}
+
+	seen := map[string]bool{}
+	unique := roots[:0]
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		if !seen[root] {
+			seen[root] = true
+			unique = append(unique, root)
+		}
+	}
+	return unique
+}
+
+// findTranscripts lists the transcripts under the projects folder of each
+// root, plus extra if it is not already among them, newest first.
+func findTranscripts(roots []string, extra string) []transcriptFile {
+	found := map[string]transcriptFile{}
+	for _, root := range roots {
+		projectsDir := filepath.Join(root, "projects")
+		filepath.WalkDir(projectsDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || filepath.Ext(path) != ".jsonl" {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				found[path] = transcriptFile{Path: path, ModTime: info.ModTime()}
+			}
+			return nil
+		})
+	}
+
+	if extra != "" {
+		if _, ok := found[extra]; !ok {
+			if info, err := os.Stat(extra); err == nil {
+				found[extra] = transcriptFile{Path: extra, ModTime: info.ModTime()}
+			}
+		}
+	}
+
+	files := make([]transcriptFile, 0, len(found))
+	for _, file := range found {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+	return files
+}
@@ -0,0 +1,88 @@
+package claude
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// subagentIdleTimeout is how long a Task may go without sidechain activity
+// before it is no longer considered running, e.g. after an interrupted session.
+const subagentIdleTimeout = 10 * time.Minute
+
+// subagentTool is the tool the main conversation uses to launch subagents.
+const subagentTool = "Task"
+
+// ClaudeSubagentMetrics describes subagent activity in a session. Subagents
+// run as sidechains of the main conversation, launched by Task tool calls.
+type ClaudeSubagentMetrics struct {
+	// Usage is the sidechain usage per model
+	Usage ModelUsage `json:"usage"`
+	// Tasks maps the ID of each Task tool call to whether it has completed
+	Tasks        map[string]bool `json:"tasks"`
+	LastActivity time.Time       `json:"lastActivity"`
+}
+
+// Invocations returns the number of Task tool calls in the session.
+func (m *ClaudeSubagentMetrics) Invocations() int {
+	return len(m.Tasks)
+}
+
+// Running returns the number of Task calls without a result yet. Tasks are
+// only considered running while there has been recent subagent activity.
+func (m *ClaudeSubagentMetrics) Running(now time.Time) int {
+	if now.Sub(m.LastActivity) > subagentIdleTimeout {
+		return 0
+	}
+	running := 0
+	for _, completed := range m.Tasks {
+		if !completed {
+			running++
+		}
+	}
+	return running
+}
+
+// contentRefs declares the parts of message content needed to follow tool
+// calls. String content, as in plain user prompts, decodes as empty.
+type contentRefs []struct {
+	Type      string `json:"type"`
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	ToolUseID string `json:"tool_use_id,omitempty"`
+}
+
+func (c *contentRefs) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] != '[' {
+		*c = nil
+		return nil
+	}
+	type plain contentRefs
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+// track records Task calls and their results, and the time of
+// sidechain activity.
+func (m *ClaudeSubagentMetrics) track(entry *usageRecord, entryTime time.Time) {
+	if entry.IsSidechain && entryTime.After(m.LastActivity) {
+		m.LastActivity = entryTime
+	}
+	if entry.Message == nil {
+		return
+	}
+
+	for _, block := range entry.Message.Content {
+		switch {
+		case block.Type == BlockToolUse && block.Name == subagentTool && !entry.IsSidechain:
+			if _, ok := m.Tasks[block.ID]; !ok {
+				m.Tasks[block.ID] = false
+				if entryTime.After(m.LastActivity) {
+					m.LastActivity = entryTime
+				}
+			}
+		case block.Type == BlockToolResult:
+			if _, ok := m.Tasks[block.ToolUseID]; ok {
+				m.Tasks[block.ToolUseID] = true
+			}
+		}
+	}
+}
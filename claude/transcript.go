@@ -0,0 +1,280 @@
+package claude
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"time"
+)
+
+// EntryType is the kind of a transcript line.
+type EntryType string
+
+const (
+	EntryUser      EntryType = "user"
+	EntryAssistant EntryType = "assistant"
+	EntrySystem    EntryType = "system"
+	EntrySummary   EntryType = "summary"
+)
+
+// TranscriptEntry represents a single entry in the session's transcript. Claude
+// Code transscripts are stored in JSONL format (newline delimited distinct JSON objects).
+//
+// Entries form a tree through UUID and ParentUUID. Sidechain entries belong
+// to subagents rather than the main conversation.
+type TranscriptEntry struct {
+	Type              EntryType `json:"type,omitempty"`
+	UUID              string    `json:"uuid,omitempty"`
+	ParentUUID        string    `json:"parentUuid,omitempty"`
+	SessionID         string    `json:"sessionId,omitempty"`
+	RequestID         string    `json:"requestId,omitempty"`
+	Timestamp         string    `json:"timestamp,omitempty"`
+	IsSidechain       bool      `json:"isSidechain,omitempty"`
+	IsAPIErrorMessage bool      `json:"isApiErrorMessage,omitempty"`
+	Cwd               string    `json:"cwd,omitempty"`
+	GitBranch         string    `json:"gitBranch,omitempty"`
+	Version           string    `json:"version,omitempty"`
+	Message           *Message  `json:"message,omitempty"`
+
+	// Set on system entries
+	Content string `json:"content,omitempty"`
+	Level   string `json:"level,omitempty"`
+
+	// Set on summary entries
+	Summary  string `json:"summary,omitempty"`
+	LeafUUID string `json:"leafUuid,omitempty"`
+}
+
+// Time parses the entry's timestamp.
+func (e *TranscriptEntry) Time() (time.Time, error) {
+	return time.Parse(time.RFC3339, e.Timestamp)
+}
+
+type Message struct {
+	ID         string  `json:"id,omitempty"`
+	Role       string  `json:"role,omitempty"`
+	Model      string  `json:"model,omitempty"`
+	Content    Content `json:"content,omitempty"`
+	StopReason string  `json:"stop_reason,omitempty"`
+	Usage      *Usage  `json:"usage,omitempty"`
+}
+
+// Content is the list of content blocks in a message. User messages may
+// carry plain string content, which is decoded as a single text block.
+type Content []ContentBlock
+
+func (c *Content) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = Content{{Type: BlockText, Text: text}}
+		return nil
+	}
+
+	var blocks []ContentBlock
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return err
+	}
+	*c = blocks
+	return nil
+}
+
+const (
+	BlockText       = "text"
+	BlockThinking   = "thinking"
+	BlockToolUse    = "tool_use"
+	BlockToolResult = "tool_result"
+)
+
+type ContentBlock struct {
+	Type string `json:"type"`
+
+	// Set on text and thinking blocks
+	Text     string `json:"text,omitempty"`
+	Thinking string `json:"thinking,omitempty"`
+
+	// Set on tool_use blocks
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// Set on tool_result blocks
+	ToolUseID string  `json:"tool_use_id,omitempty"`
+	Content   Content `json:"content,omitempty"`
+	IsError   bool    `json:"is_error,omitempty"`
+}
+
+type Usage struct {
+	InputTokens              int64          `json:"input_tokens,omitempty"`
+	OutputTokens             int64          `json:"output_tokens,omitempty"`
+	CacheReadInputTokens     int64          `json:"cache_read_input_tokens,omitempty"`
+	CacheCreationInputTokens int64          `json:"cache_creation_input_tokens,omitempty"`
+	CacheCreation            *CacheCreation `json:"cache_creation,omitempty"`
+	ServerToolUse            *ServerToolUse `json:"server_tool_use,omitempty"`
+	ServiceTier              string         `json:"service_tier,omitempty"`
+}
+
+// CacheCreation splits CacheCreationInputTokens by cache lifetime.
+type CacheCreation struct {
+	Ephemeral5mInputTokens int64 `json:"ephemeral_5m_input_tokens,omitempty"`
+	Ephemeral1hInputTokens int64 `json:"ephemeral_1h_input_tokens,omitempty"`
+}
+
+type ServerToolUse struct {
+	WebSearchRequests int64 `json:"web_search_requests,omitempty"`
+	WebFetchRequests  int64 `json:"web_fetch_requests,omitempty"`
+}
+
+// usageRecord declares only the fields needed for metrics. Decoding into it
+// skips message content, which makes up nearly all of a transcript's bytes.
+type usageRecord struct {
+	UUID        string `json:"uuid,omitempty"`
+	RequestID   string `json:"requestId,omitempty"`
+	Timestamp   string `json:"timestamp,omitempty"`
+	IsSidechain bool   `json:"isSidechain,omitempty"`
+	Message     *struct {
+		ID      string      `json:"id,omitempty"`
+		Model   string      `json:"model,omitempty"`
+		Content contentRefs `json:"content,omitempty"`
+		Usage   *Usage      `json:"usage,omitempty"`
+	} `json:"message,omitempty"`
+}
+
+// usageKey identifies the API response a record's usage belongs to. Claude Code
+// writes one line per content block of an assistant message, and resumed
+// sessions copy earlier lines, so the same usage appears several times. The
+// message and request IDs identify the response; the entry UUID is used when
+// they are missing. An empty key means the entry cannot be deduplicated.
+func (r *usageRecord) usageKey() string {
+	if r.Message != nil && r.Message.ID != "" {
+		return r.Message.ID + ":" + r.RequestID
+	}
+	return r.UUID
+}
+
+// TranscriptReader reads a transcript one line at a time. Unlike a default
+// bufio.Scanner it has no line length limit, which matters because tool
+// results routinely produce lines of several megabytes.
+//
+// Claude Code appends to the transcript while the statusline runs, so a final
+// line without a trailing newline is treated as incomplete: it is not returned,
+// is not counted in Offset, and is reported by Partial instead.
+type TranscriptReader struct {
+	r       *bufio.Reader
+	line    []byte
+	offset  int64
+	partial bool
+	err     error
+}
+
+// NewTranscriptReader returns a reader positioned at the start of r.
+func NewTranscriptReader(r io.Reader) *TranscriptReader {
+	return &TranscriptReader{r: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// Next advances to the next complete, non-empty line. It returns false at the
+// end of the input or on a read error, which is then available from Err.
+func (t *TranscriptReader) Next() bool {
+	for t.err == nil {
+		t.line = t.line[:0]
+		for {
+			chunk, err := t.r.ReadSlice('\n')
+			t.line = append(t.line, chunk...)
+			if err == bufio.ErrBufferFull {
+				continue
+			}
+			if err != nil {
+				t.partial = len(t.line) > 0
+				if !errors.Is(err, io.EOF) {
+					t.err = err
+				}
+				return false
+			}
+			break
+		}
+
+		t.offset += int64(len(t.line))
+		if line := bytes.TrimSpace(t.line); len(line) > 0 {
+			t.line = line
+			return true
+		}
+	}
+	return false
+}
+
+// Bytes returns the current line. It is only valid until the next call to Next.
+func (t *TranscriptReader) Bytes() []byte {
+	return t.line
+}
+
+// Decode unmarshals the current line into v. Passing a struct that declares
+// only the needed fields keeps decoding cheap for large lines.
+func (t *TranscriptReader) Decode(v any) error {
+	return json.Unmarshal(t.line, v)
+}
+
+// Offset returns the number of bytes consumed by complete lines so far.
+func (t *TranscriptReader) Offset() int64 {
+	return t.offset
+}
+
+// Partial reports whether the input ended in an incomplete line.
+func (t *TranscriptReader) Partial() bool {
+	return t.partial
+}
+
+// Err returns the first read error, if any. Reaching the end of the input is
+// not an error.
+func (t *TranscriptReader) Err() error {
+	return t.err
+}
+
+// ReadTranscript returns an iterator over the entries of the transcript file
+// at path. See Entries for how errors are reported.
+func ReadTranscript(path string) iter.Seq2[*TranscriptEntry, error] {
+	return func(yield func(*TranscriptEntry, error) bool) {
+		file, err := os.Open(path)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer file.Close()
+
+		for entry, err := range Entries(file) {
+			if !yield(entry, err) {
+				return
+			}
+		}
+	}
+}
+
+// Entries returns an iterator over the transcript entries read from r. A line
+// that fails to decode yields an error and iteration continues with the next
+// line; a read error is yielded last. An incomplete final line is skipped.
+func Entries(r io.Reader) iter.Seq2[*TranscriptEntry, error] {
+	return func(yield func(*TranscriptEntry, error) bool) {
+		reader := NewTranscriptReader(r)
+		for reader.Next() {
+			offset := reader.Offset()
+
+			var entry TranscriptEntry
+			if err := reader.Decode(&entry); err != nil {
+				if !yield(nil, fmt.Errorf("invalid transcript line ending at byte %d: %w", offset, err)) {
+					return
+				}
+				continue
+			}
+			if !yield(&entry, nil) {
+				return
+			}
+		}
+
+		if err := reader.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package claude
+
+import (
+	"bytes"
+	"encoding/json"
+	"iter"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTranscriptReaderLongLine(t *testing.T) {
+	// Well past the reader's 64 KiB buffer, as large tool results are
+	long := `{"type": "user", "content": "` + strings.Repeat("x", 300*1024) + `"}`
+	input := long + "\n\n" + `{"type": "summary"}` + "\n"
+
+	reader := NewTranscriptReader(strings.NewReader(input))
+	if !reader.Next() || string(reader.Bytes()) != long {
+		t.Fatalf("first line has %d bytes, want %d", len(reader.Bytes()), len(long))
+	}
+	if got, want := reader.Offset(), int64(len(long)+1); got != want {
+		t.Errorf("offset after the long line = %d, want %d", got, want)
+	}
+	// Blank lines are skipped
+	if !reader.Next() || string(reader.Bytes()) != `{"type": "summary"}` {
+		t.Fatalf("second line = %q", reader.Bytes())
+	}
+	if reader.Next() {
+		t.Errorf("unexpected line %q", reader.Bytes())
+	}
+	if reader.Offset() != int64(len(input)) || reader.Partial() || reader.Err() != nil {
+		t.Errorf("at end: offset %d, partial %v, err %v, want %d, false, nil", reader.Offset(), reader.Partial(), reader.Err(), len(input))
+	}
+}
+
+func TestTranscriptReaderPartialLine(t *testing.T) {
+	complete := `{"type": "user"}` + "\n"
+	for _, partial := range []string{`{"type": "assist`, strings.Repeat("y", 100*1024)} {
+		reader := NewTranscriptReader(strings.NewReader(complete + partial))
+		lines := 0
+		for reader.Next() {
+			lines++
+		}
+		if lines != 1 {
+			t.Errorf("read %d lines, want the complete one only", lines)
+		}
+		if !reader.Partial() {
+			t.Error("Partial() = false with an unterminated final line")
+		}
+		if got := reader.Offset(); got != int64(len(complete)) {
+			t.Errorf("offset = %d, want %d, excluding the partial line", got, len(complete))
+		}
+		if reader.Err() != nil {
+			t.Errorf("Err() = %v, an incomplete line is not an error", reader.Err())
+		}
+	}
+}
+
+func TestPartialLineFinishedOnLaterRefresh(t *testing.T) {
+	data, err := os.ReadFile("testdata/dedup/session.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stop in the middle of msg_02's first line
+	cut := bytes.Index(data, []byte(`"uuid": "a-2a"`)) + 10
+
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := os.WriteFile(path, data[:cut], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache := newTranscriptCache("")
+	summary, err := cache.summarize(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summary.Tokens.OutputTokens; got != 340 {
+		t.Errorf("output tokens before the line is finished = %d, want 340", got)
+	}
+	if want := int64(bytes.LastIndexByte(data[:cut], '\n') + 1); summary.Offset != want {
+		t.Errorf("offset = %d, want %d, the end of the last complete line", summary.Offset, want)
+	}
+
+	// Claude Code finishes the line and goes on writing
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write(data[cut:]); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	summary, err = cache.summarize(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := summary.Tokens.OutputTokens; got != 340+120+40 {
+		t.Errorf("output tokens once the line is finished = %d, want %d", got, 340+120+40)
+	}
+	if summary.Offset != int64(len(data)) {
+		t.Errorf("offset = %d, want %d", summary.Offset, len(data))
+	}
+}
+
+func TestContentUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want Content
+	}{
+		{
+			name: "string",
+			json: `"hello"`,
+			want: Content{{Type: BlockText, Text: "hello"}},
+		},
+		{
+			name: "blocks",
+			json: `[{"type": "thinking", "thinking": "hmm"}, {"type": "text", "text": "hi"}, {"type": "tool_use", "id": "toolu_01", "name": "Bash", "input": {"command": "ls"}}]`,
+			want: Content{
+				{Type: BlockThinking, Thinking: "hmm"},
+				{Type: BlockText, Text: "hi"},
+				{Type: BlockToolUse, ID: "toolu_01", Name: "Bash", Input: json.RawMessage(`{"command": "ls"}`)},
+			},
+		},
+		{
+			name: "tool result with string content",
+			json: `[{"type": "tool_result", "tool_use_id": "toolu_01", "content": "file.txt", "is_error": true}]`,
+			want: Content{{Type: BlockToolResult, ToolUseID: "toolu_01", IsError: true, Content: Content{{Type: BlockText, Text: "file.txt"}}}},
+		},
+		{
+			name: "tool result with block content",
+			json: `[{"type": "tool_result", "tool_use_id": "toolu_02", "content": [{"type": "text", "text": "a"}, {"type": "text", "text": "b"}]}]`,
+			want: Content{{Type: BlockToolResult, ToolUseID: "toolu_02", Content: Content{{Type: BlockText, Text: "a"}, {Type: BlockText, Text: "b"}}}},
+		},
+		{
+			name: "empty",
+			json: `[]`,
+			want: Content{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got Content
+			if err := json.Unmarshal([]byte(test.json), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Content = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestContentUnmarshalInvalid(t *testing.T) {
+	for _, input := range []string{`42`, `{"type": "text"}`, `[{"type": 1}]`} {
+		var content Content
+		if err := json.Unmarshal([]byte(input), &content); err == nil {
+			t.Errorf("unmarshaling %s succeeded with %+v, want an error", input, content)
+		}
+	}
+}
+
+// entryTypes collects the type of every entry, or "error" for each error.
+func entryTypes(entries iter.Seq2[*TranscriptEntry, error]) []string {
+	var types []string
+	for entry, err := range entries {
+		if err != nil {
+			types = append(types, "error")
+			continue
+		}
+		types = append(types, string(entry.Type))
+	}
+	return types
+}
+
+func TestEntries(t *testing.T) {
+	input := `{"type": "user", "uuid": "u1", "message": {"role": "user", "content": "hi"}}` + "\n" +
+		`{"type": "assistant", "uuid": "a1", "parentUuid": "u1"` + "\n" +
+		`not json` + "\n" +
+		`{"type": "assistant", "uuid": "a2", "parentUuid": "u1", "message": {"role": "assistant", "content": [{"type": "text", "text": "hello"}]}}` + "\n" +
+		`{"type": "summary", "summary": "Greeting", "leafUuid": "a2"}` + "\n" +
+		`{"type": "user", "uuid": "u2"`
+
+	got := entryTypes(Entries(strings.NewReader(input)))
+	want := []string{"user", "error", "error", "assistant", "summary"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+
+	for entry, err := range Entries(strings.NewReader(input)) {
+		if err != nil {
+			if !strings.Contains(err.Error(), "invalid transcript line ending at byte") {
+				t.Errorf("unexpected error %v", err)
+			}
+			continue
+		}
+		if entry.Type == EntryAssistant && (entry.Message == nil || entry.Message.Content[0].Text != "hello") {
+			t.Errorf("assistant entry = %+v", entry)
+		}
+	}
+
+	// Breaking out early panics if the iterator keeps yielding
+	for _, err := range Entries(strings.NewReader(input)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		break
+	}
+}
+
+func TestReadTranscript(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	data := `{"type": "user", "uuid": "u1"}` + "\n" + `{"type": "system", "content": "compacted", "level": "info"}` + "\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entryTypes(ReadTranscript(path)), []string{"user", "system"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+
+	var errs int
+	for entry, err := range ReadTranscript(filepath.Join(t.TempDir(), "missing.jsonl")) {
+		if err == nil || entry != nil || !os.IsNotExist(err) {
+			t.Errorf("missing transcript yielded %+v, %v", entry, err)
+		}
+		errs++
+	}
+	if errs != 1 {
+		t.Errorf("missing transcript yielded %d errors, want 1", errs)
+	}
+}
@@ -0,0 +1,199 @@
+package claude
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// UsageTotals accumulates token usage across API responses.
+type UsageTotals struct {
+	InputTokens         int64 `json:"inputTokens"`
+	OutputTokens        int64 `json:"outputTokens"`
+	CacheCreationTokens int64 `json:"cacheCreationTokens"`
+	CacheReadTokens     int64 `json:"cacheReadTokens"`
+	Messages            int64 `json:"messages"`
+
+	// CacheCreation1hTokens is the part of CacheCreationTokens written to
+	// the 1 hour cache; the rest went to the 5 minute cache.
+	CacheCreation1hTokens int64 `json:"cacheCreation1hTokens"`
+	WebSearchRequests     int64 `json:"webSearchRequests"`
+}
+
+func (u *UsageTotals) TotalTokens() int64 {
+	return u.InputTokens + u.OutputTokens + u.CacheCreationTokens + u.CacheReadTokens
+}
+
+// LimitTokens returns the tokens counted towards plan usage limits. Cache
+// reads and writes are excluded; they dominate totals but count far less.
+func (u *UsageTotals) LimitTokens() int64 {
+	return u.InputTokens + u.OutputTokens
+}
+
+func (u *UsageTotals) addUsage(usage *Usage) {
+	u.InputTokens += usage.InputTokens
+	u.OutputTokens += usage.OutputTokens
+	u.CacheCreationTokens += usage.CacheCreationInputTokens
+	u.CacheReadTokens += usage.CacheReadInputTokens
+	u.Messages++
+	if usage.CacheCreation != nil {
+		u.CacheCreation1hTokens += usage.CacheCreation.Ephemeral1hInputTokens
+	}
+	if usage.ServerToolUse != nil {
+		u.WebSearchRequests += usage.ServerToolUse.WebSearchRequests
+	}
+}
+
+func (u *UsageTotals) add(other *UsageTotals) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheCreationTokens += other.CacheCreationTokens
+	u.CacheReadTokens += other.CacheReadTokens
+	u.Messages += other.Messages
+	u.CacheCreation1hTokens += other.CacheCreation1hTokens
+	u.WebSearchRequests += other.WebSearchRequests
+}
+
+// ModelUsage holds usage totals keyed by model ID. Usage is kept per model
+// so it can be priced when it is reported.
+type ModelUsage map[string]*UsageTotals
+
+func (m ModelUsage) addUsage(model string, usage *Usage) {
+	if m[model] == nil {
+		m[model] = &UsageTotals{}
+	}
+	m[model].addUsage(usage)
+}
+
+func (m ModelUsage) add(other ModelUsage) {
+	for model, totals := range other {
+		if m[model] == nil {
+			m[model] = &UsageTotals{}
+		}
+		m[model].add(totals)
+	}
+}
+
+// Totals sums usage across all models.
+func (m ModelUsage) Totals() UsageTotals {
+	var totals UsageTotals
+	for _, usage := range m {
+		totals.add(usage)
+	}
+	return totals
+}
+
+// CostUSD prices the usage of every model.
+func (m ModelUsage) CostUSD() float64 {
+	var cost float64
+	for model, usage := range m {
+		cost += priceTotals(model, usage)
+	}
+	return cost
+}
+
+// ModelCost is one model's share of usage.
+type ModelCost struct {
+	Model   string
+	Usage   UsageTotals
+	CostUSD float64
+}
+
+// Breakdown returns usage and cost per model family (see ModelFamily), most
+// expensive first. Models without usage are left out.
+func (m ModelUsage) Breakdown() []ModelCost {
+	byFamily := map[string]*ModelCost{}
+	for model, usage := range m {
+		if usage.TotalTokens() == 0 {
+			continue
+		}
+		family := ModelFamily(model)
+		if byFamily[family] == nil {
+			byFamily[family] = &ModelCost{Model: family}
+		}
+		byFamily[family].Usage.add(usage)
+		byFamily[family].CostUSD += priceTotals(model, usage)
+	}
+
+	breakdown := make([]ModelCost, 0, len(byFamily))
+	for _, cost := range byFamily {
+		breakdown = append(breakdown, *cost)
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].CostUSD != breakdown[j].CostUSD {
+			return breakdown[i].CostUSD > breakdown[j].CostUSD
+		}
+		return breakdown[i].Model < breakdown[j].Model
+	})
+	return breakdown
+}
+
+// ModelFamily returns a short name for a model ID, such as "Opus" for
+// "claude-opus-4-1-20250805", or the ID itself for unknown models.
+func ModelFamily(model string) string {
+	for _, family := range []string{"opus", "sonnet", "haiku"} {
+		if strings.Contains(model, family) {
+			return strings.ToUpper(family[:1]) + family[1:]
+		}
+	}
+	return model
+}
+
+// usageBuckets groups usage into fixed time buckets keyed by the bucket's
+// index since the Unix epoch.
+type usageBuckets map[int64]ModelUsage
+
+func (b usageBuckets) addUsage(key int64, model string, usage *Usage) {
+	if b[key] == nil {
+		b[key] = ModelUsage{}
+	}
+	b[key].addUsage(model, usage)
+}
+
+func (b usageBuckets) merge(other usageBuckets) {
+	for key, usage := range other {
+		if b[key] == nil {
+			b[key] = ModelUsage{}
+		}
+		b[key].add(usage)
+	}
+}
+
+// between sums the buckets with keys in [from, to).
+func (b usageBuckets) between(from, to int64) ModelUsage {
+	sum := ModelUsage{}
+	for key, usage := range b {
+		if key >= from && key < to {
+			sum.add(usage)
+		}
+	}
+	return sum
+}
+
+// activeBetween counts the buckets with usage with keys in [from, to).
+func (b usageBuckets) activeBetween(from, to int64) int {
+	active := 0
+	for key, usage := range b {
+		if key >= from && key < to && len(usage) > 0 {
+			active++
+		}
+	}
+	return active
+}
+
+// prune drops buckets with keys before from.
+func (b usageBuckets) prune(from int64) {
+	for key := range b {
+		if key < from {
+			delete(b, key)
+		}
+	}
+}
+
+func hourKey(t time.Time) int64 {
+	return t.Unix() / 3600
+}
+
+func minuteKey(t time.Time) int64 {
+	return t.Unix() / 60
+}
@@ -0,0 +1,53 @@
+package claude
+
+import "time"
+
+// WeeklyReset is when the weekly usage window resets.
+type WeeklyReset struct {
+	Weekday  time.Weekday
+	Hour     int
+	Minute   int
+	Location *time.Location
+}
+
+// WindowStart returns the most recent reset at or before now.
+func (r WeeklyReset) WindowStart(now time.Time) time.Time {
+	location := r.Location
+	if location == nil {
+		location = time.Local
+	}
+
+	local := now.In(location)
+	start := time.Date(local.Year(), local.Month(), local.Day(), r.Hour, r.Minute, 0, 0, location)
+	start = start.AddDate(0, 0, -int((local.Weekday()-r.Weekday+7)%7))
+	if start.After(local) {
+		start = start.AddDate(0, 0, -7)
+	}
+	return start
+}
+
+// WeeklyUsage is the usage during a weekly limit window.
+type WeeklyUsage struct {
+	StartTime time.Time   `json:"startTime"`
+	EndTime   time.Time   `json:"endTime"`
+	Usage     UsageTotals `json:"usage"`
+	CostUSD   float64     `json:"costUSD"`
+	// ActiveHours counts the hours in the window with any usage
+	ActiveHours int `json:"activeHours"`
+}
+
+// Weekly returns the usage in the weekly window containing now. Usage is
+// bucketed by hour, so a reset that is not on the hour is floored to it.
+func (h *UsageHistory) Weekly(now time.Time, reset WeeklyReset) *WeeklyUsage {
+	start := reset.WindowStart(now)
+	end := start.AddDate(0, 0, 7)
+
+	used := h.Between(start, end)
+	return &WeeklyUsage{
+		StartTime:   start,
+		EndTime:     end,
+		Usage:       used.Totals(),
+		CostUSD:     used.CostUSD(),
+		ActiveHours: h.hourly.activeBetween(hourKey(start), hourKey(end)),
+	}
+}
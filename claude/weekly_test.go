@@ -0,0 +1,87 @@
+package claude
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowStart(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, newYork)
+	}
+	monday := WeeklyReset{Weekday: time.Monday, Hour: 9, Location: newYork}
+
+	tests := []struct {
+		name  string
+		reset WeeklyReset
+		now   time.Time
+		want  time.Time
+	}{
+		{"later in the week", monday, at(time.October, 14, 12, 0), at(time.October, 12, 9, 0)},
+		{"at the reset", monday, at(time.October, 12, 9, 0), at(time.October, 12, 9, 0)},
+		{"reset later the same day", monday, at(time.October, 12, 8, 59), at(time.October, 5, 9, 0)},
+		{"reset earlier the same day", monday, at(time.October, 12, 23, 59), at(time.October, 12, 9, 0)},
+		{
+			name:  "reset day before now's weekday wraps around",
+			reset: WeeklyReset{Weekday: time.Saturday, Hour: 18, Minute: 30, Location: newYork},
+			now:   at(time.October, 12, 9, 0),
+			want:  at(time.October, 10, 18, 30),
+		},
+		{
+			name:  "reset day after now's weekday",
+			reset: WeeklyReset{Weekday: time.Sunday, Location: newYork},
+			now:   at(time.October, 17, 23, 0),
+			want:  at(time.October, 11, 0, 0),
+		},
+		{
+			// 2026-10-12 01:00 UTC is still Sunday evening in New York
+			name:  "weekday taken in the reset's zone",
+			reset: monday,
+			now:   time.Date(2026, time.October, 12, 1, 0, 0, 0, time.UTC),
+			want:  at(time.October, 5, 9, 0),
+		},
+		{
+			// Clocks go back an hour on 2026-11-01; the reset stays at 09:00
+			name:  "first reset after the end of DST",
+			reset: monday,
+			now:   at(time.November, 2, 10, 0),
+			want:  at(time.November, 2, 9, 0),
+		},
+		{
+			name:  "window starting before the end of DST",
+			reset: WeeklyReset{Weekday: time.Saturday, Hour: 9, Location: newYork},
+			now:   at(time.November, 2, 10, 0),
+			want:  at(time.October, 31, 9, 0),
+		},
+		{
+			// Clocks go forward an hour on 2026-03-08
+			name:  "window spanning the start of DST",
+			reset: WeeklyReset{Weekday: time.Saturday, Hour: 9, Location: newYork},
+			now:   at(time.March, 13, 12, 0),
+			want:  at(time.March, 7, 9, 0),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.reset.WindowStart(test.now)
+			if !got.Equal(test.want) {
+				t.Errorf("WindowStart(%v) = %v, want %v", test.now, got, test.want)
+			}
+			if got.Location() != newYork {
+				t.Errorf("WindowStart(%v) is in %v, want %v", test.now, got.Location(), newYork)
+			}
+		})
+	}
+
+	// The reset across a DST change is 09:00 local, not 168 hours later
+	start := WeeklyReset{Weekday: time.Saturday, Hour: 9, Location: newYork}.WindowStart(at(time.October, 31, 9, 0))
+	next := start.AddDate(0, 0, 7)
+	if got := next.Sub(start); got != 169*time.Hour {
+		t.Errorf("window across the end of DST lasts %v, want 169h", got)
+	}
+}
@@ -0,0 +1,437 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config holds user settings for cstatus. Settings are read from the global
+// config file first and then overlaid with the optional project config, so a
+// project only needs to specify the keys it wants to change.
+type Config struct {
+	// Widgets lists the widgets to render, in order, by name.
+	Widgets []string      `json:"widgets"`
+	History HistoryConfig `json:"history"`
+	Block   BlockConfig   `json:"block"`
+	Burn    BurnConfig    `json:"burn"`
+	Limit   LimitConfig   `json:"limit"`
+	Weekly  WeeklyConfig  `json:"weekly"`
+	Model   ModelConfig   `json:"model"`
+	Git     GitConfig     `json:"git"`
+	VCS     VCSConfig     `json:"vcs"`
+	Links   LinksConfig   `json:"links"`
+
+	Freshness FreshnessConfig `json:"freshness"`
+	Diff      DiffConfig      `json:"diff"`
+	Directory DirectoryConfig `json:"directory"`
+
+	Lines      ThresholdConfig `json:"lines"`
+	Duration   ThresholdConfig `json:"duration"`
+	APIShare   ThresholdConfig `json:"api_share"`
+	Efficiency ThresholdConfig `json:"efficiency"`
+}
+
+// HistoryConfig controls recording of statusline invocations for later replay.
+type HistoryConfig struct {
+	Enabled bool   `json:"enabled"`
+	Path    string `json:"path"`
+}
+
+// BlockConfig controls the block widget.
+type BlockConfig struct {
+	// Show lists the views to render, in order: "elapsed", "remaining",
+	// "reset" (the clock time the block resets), "progress" and "usage"
+	// (tokens and cost used so far).
+	Show []string `json:"show"`
+	// TimeZone is the IANA zone for the reset clock; empty means local time.
+	TimeZone string `json:"time_zone"`
+	// Clock is "24h" or "12h".
+	Clock string `json:"clock"`
+	// ProgressWidth is the number of cells in the progress bar; 0 or less
+	// uses the default of 10.
+	ProgressWidth int `json:"progress_width"`
+	// WarnMinutes highlights the widget when fewer minutes remain; 0 disables it.
+	WarnMinutes int `json:"warn_minutes"`
+}
+
+// BurnConfig controls the burn rate widget.
+type BurnConfig struct {
+	// Show lists the views to render, in order: "tokens" and "cost" per
+	// minute, and "projection" (block totals at the current rate).
+	Show []string `json:"show"`
+}
+
+// LimitConfig controls the usage limit widget.
+type LimitConfig struct {
+	// Plan is "pro", "max5" or "max20" and selects an estimated block limit.
+	Plan string `json:"plan"`
+	// Tokens overrides the plan's block limit.
+	Tokens int64 `json:"tokens"`
+	// Mode is "plan" to use the plan limit, or "learned" to use the most
+	// tokens used in any previous block, falling back to the plan limit.
+	Mode       string     `json:"mode"`
+	Thresholds Thresholds `json:"thresholds"`
+}
+
+// WeeklyConfig sets when the weekly usage window resets.
+type WeeklyConfig struct {
+	// ResetDay is the weekday name, e.g. "monday".
+	ResetDay string `json:"reset_day"`
+	// ResetTime is the time of day in 24h "HH:MM" form.
+	ResetTime string `json:"reset_time"`
+	// TimeZone is the IANA zone of the reset; empty means local time.
+	TimeZone string `json:"time_zone"`
+}
+
+// ModelConfig controls the model widget.
+type ModelConfig struct {
+	// Split shows the session's cost per model instead of the current model
+	// name, e.g. "Opus $3.10 · Haiku $0.12".
+	Split bool `json:"split"`
+}
+
+// GitConfig controls the git widget.
+type GitConfig struct {
+	// Show lists the indicators to render, in order: "branch", "operation"
+	// (a rebase, merge, cherry-pick, revert or bisect in progress), "tag"
+	// (the nearest tag and commits since it), "worktree" (the name of a
+	// linked worktree), "ahead_behind", "staged", "unstaged", "untracked",
+	// "conflicted" and "stash". Counts that are zero are hidden.
+	Show []string `json:"show"`
+	// DirtyFg and DirtyBg replace the widget's colors when the tree has changes.
+	DirtyFg string       `json:"dirty_fg"`
+	DirtyBg string       `json:"dirty_bg"`
+	Branch  BranchConfig `json:"branch"`
+}
+
+// VCSConfig controls the vcs widget, which shows the git widget in git
+// repositories and its own views in Jujutsu and Mercurial ones. The git
+// settings, including branch rewriting and dirty colors, apply to all of them.
+type VCSConfig struct {
+	// Backends lists the repository kinds to look for: "jj", "hg" and "git".
+	// The innermost repository wins, and ties go to the backend listed first.
+	Backends []string `json:"backends"`
+}
+
+// BranchConfig rewrites branch names for display. The steps run in field
+// order: the ticket is extracted, prefixes stripped, replacements applied and
+// the result shortened.
+type BranchConfig struct {
+	// Ticket is a regular expression matching a ticket key such as
+	// "[A-Z]+-[0-9]+". The key is removed from the name and shown in its own
+	// sub-segment; if the expression has a group, the first group is shown.
+	Ticket   string `json:"ticket"`
+	TicketFg string `json:"ticket_fg"`
+	TicketBg string `json:"ticket_bg"`
+	// StripPrefixes removes the first matching prefix, e.g. "feature/".
+	StripPrefixes []string `json:"strip_prefixes"`
+	// Replace applies regular expression replacements in order.
+	Replace Replacements `json:"replace"`
+	// MaxLength shortens longer names with an ellipsis in the middle; 0
+	// disables it.
+	MaxLength int `json:"max_length"`
+}
+
+// Replacement is a regular expression and its replacement, which may refer
+// to groups as $1.
+type Replacement struct {
+	Pattern string `json:"pattern"`
+	With    string `json:"with"`
+}
+
+// Replacements replace any list from an earlier config file as a whole.
+type Replacements []Replacement
+
+func (r *Replacements) UnmarshalJSON(data []byte) error {
+	return decodeFresh(data, (*[]Replacement)(r))
+}
+
+// FreshnessConfig controls the freshness widget.
+type FreshnessConfig struct {
+	// Show lists the views to render, in order: "commit" (time since the
+	// last commit), "unpushed" (commits on no remote branch), "diverged"
+	// (commits since the default branch) and "fetch" (time since the last
+	// fetch). Counts that are zero are hidden.
+	Show []string `json:"show"`
+	// Thresholds apply to the number of unpushed commits.
+	Thresholds Thresholds `json:"thresholds"`
+}
+
+// DiffConfig controls the diff widget.
+type DiffConfig struct {
+	// Exclude lists globs of paths left out of the diff, e.g. "**/*.pb.go".
+	Exclude []string `json:"exclude"`
+	// MaxAgeSeconds is how long a cached diff stat is reused while the index
+	// and HEAD are unchanged.
+	MaxAgeSeconds int `json:"max_age_seconds"`
+	// Thresholds apply to the number of changed lines.
+	Thresholds Thresholds `json:"thresholds"`
+}
+
+// DirectoryConfig controls the directory widget.
+type DirectoryConfig struct {
+	// AbbreviateLength is how many characters of each intermediate directory
+	// are kept; 0 disables abbreviation.
+	AbbreviateLength int `json:"abbreviate_length"`
+	// MaxDepth is the most directories shown after the root, with an
+	// ellipsis for the rest; 0 shows all of them. The innermost anchor is
+	// kept even when it falls outside.
+	MaxDepth int `json:"max_depth"`
+	// Anchors are file names marking directories that are never abbreviated,
+	// such as module roots.
+	Anchors []string `json:"anchors"`
+	// Aliases maps directories to short labels shown in their place, e.g.
+	// {"~/src/github.com/acme/monorepo": "mono"}.
+	Aliases map[string]string `json:"aliases"`
+}
+
+// LinksConfig controls the hyperlinks widgets attach to their segments: the
+// git widget links to a comparison of the branch, the project widget to the
+// repository and the session widget to the transcript file.
+type LinksConfig struct {
+	// Mode is "auto" to link only in terminals known to support OSC 8
+	// hyperlinks, "always" or "never".
+	Mode string `json:"mode"`
+	// Hosts maps self-hosted forge hostnames to a key of Forges. Hosts whose
+	// name contains "github", "gitlab" or "gitea" are recognized without it.
+	Hosts map[string]string `json:"hosts"`
+	// Forges holds URL templates by forge name. An entry for a forge that
+	// is already defined only needs the templates it changes.
+	Forges map[string]ForgeTemplates `json:"forges"`
+}
+
+// ForgeTemplates are URL templates for a forge. They may use {host},
+// {owner}, {repo}, {branch} and {base} (the remote's default branch).
+type ForgeTemplates struct {
+	Repo    string `json:"repo"`
+	Compare string `json:"compare"`
+}
+
+// ThresholdConfig holds color thresholds for widgets with no other options.
+// Thresholds apply to the widget's value: changed lines for lines, minutes
+// for duration, percent for api_share and USD per 100 lines for efficiency.
+type ThresholdConfig struct {
+	Thresholds Thresholds `json:"thresholds"`
+}
+
+// Threshold switches a widget's colors once its value reaches Above.
+type Threshold struct {
+	Above float64 `json:"above"`
+	Fg    string  `json:"fg"`
+	Bg    string  `json:"bg"`
+}
+
+// Thresholds replace any list from an earlier config file as a whole.
+type Thresholds []Threshold
+
+func (t *Thresholds) UnmarshalJSON(data []byte) error {
+	return decodeFresh(data, (*[]Threshold)(t))
+}
+
+// decodeFresh decodes a JSON array into a new slice. Decoding into the
+// existing one would reuse its elements, leaking fields the new list leaves
+// out.
+func decodeFresh[T any](data []byte, s *[]T) error {
+	var fresh []T
+	if err := json.Unmarshal(data, &fresh); err != nil {
+		return err
+	}
+	*s = fresh
+	return nil
+}
+
+// Colors returns the colors of the highest threshold value has reached, or
+// fg and bg if it has reached none.
+func (t Thresholds) Colors(value float64, fg, bg string) (string, string) {
+	best := -1
+	for i, threshold := range t {
+		if value >= threshold.Above && (best < 0 || threshold.Above > t[best].Above) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return fg, bg
+	}
+	if t[best].Fg != "" {
+		fg = t[best].Fg
+	}
+	if t[best].Bg != "" {
+		bg = t[best].Bg
+	}
+	return fg, bg
+}
+
+// Default returns the configuration used when no config file is present.
+func Default() *Config {
+	return &Config{
+		Widgets: []string{"project", "vcs", "session", "context", "block"},
+		History: HistoryConfig{
+			Path: defaultHistoryPath(),
+		},
+		Block: BlockConfig{
+			Show:          []string{"elapsed"},
+			Clock:         "24h",
+			ProgressWidth: 10,
+		},
+		Burn: BurnConfig{
+			Show: []string{"tokens", "cost", "projection"},
+		},
+		Limit: LimitConfig{
+			Plan: "pro",
+			Mode: "plan",
+			Thresholds: Thresholds{
+				{Above: 50, Fg: "#000000", Bg: "#ffd700"},
+				{Above: 80, Fg: "#ffffff", Bg: "#cc3333"},
+			},
+		},
+		Weekly: WeeklyConfig{
+			ResetDay:  "monday",
+			ResetTime: "00:00",
+		},
+		Git: GitConfig{
+			Show:    []string{"branch", "operation", "worktree", "ahead_behind", "staged", "unstaged", "untracked", "conflicted", "stash"},
+			DirtyFg: "#000000",
+			DirtyBg: "#ffa500",
+			Branch: BranchConfig{
+				TicketFg: "#ffffff",
+				TicketBg: "#0052cc",
+			},
+		},
+		VCS: VCSConfig{
+			Backends: []string{"jj", "hg", "git"},
+		},
+		Freshness: FreshnessConfig{
+			Show: []string{"commit", "unpushed", "diverged", "fetch"},
+			Thresholds: Thresholds{
+				{Above: 5, Fg: "#000000", Bg: "#ffd700"},
+				{Above: 20, Fg: "#ffffff", Bg: "#cc3333"},
+			},
+		},
+		Directory: DirectoryConfig{
+			AbbreviateLength: 1,
+			MaxDepth:         4,
+			Anchors:          []string{"go.mod", "package.json"},
+			Aliases:          map[string]string{},
+		},
+		Diff: DiffConfig{
+			MaxAgeSeconds: 5,
+		},
+		Links: LinksConfig{
+			Mode:  "auto",
+			Hosts: map[string]string{},
+			Forges: map[string]ForgeTemplates{
+				"github": {
+					Repo:    "https://{host}/{owner}/{repo}",
+					Compare: "https://{host}/{owner}/{repo}/compare/{base}...{branch}",
+				},
+				"gitlab": {
+					Repo:    "https://{host}/{owner}/{repo}",
+					Compare: "https://{host}/{owner}/{repo}/-/compare/{base}...{branch}",
+				},
+				"gitea": {
+					Repo:    "https://{host}/{owner}/{repo}",
+					Compare: "https://{host}/{owner}/{repo}/compare/{base}...{branch}",
+				},
+			},
+		},
+	}
+}
+
+// GlobalPath returns the location of the global config file. The
+// CSTATUS_CONFIG environment variable takes precedence over the default.
+func GlobalPath() string {
+	if path := os.Getenv("CSTATUS_CONFIG"); path != "" {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".claude", "cstatus.json")
+}
+
+// ProjectPath returns the location of the project config file for projectDir.
+func ProjectPath(projectDir string) string {
+	if projectDir == "" {
+		return ""
+	}
+	return filepath.Join(projectDir, ".claude", "cstatus.json")
+}
+
+// Load reads the global config and overlays the project config for projectDir.
+// Missing files are not an error.
+func Load(projectDir string) (*Config, error) {
+	return LoadFiles(GlobalPath(), ProjectPath(projectDir))
+}
+
+// LoadFiles starts from the defaults and overlays each file in order.
+func LoadFiles(paths ...string) (*Config, error) {
+	cfg := Default()
+	for _, path := range paths {
+		if err := cfg.overlay(path); err != nil {
+			return nil, err
+		}
+	}
+	cfg.History.Path = ExpandHome(cfg.History.Path)
+	return cfg, nil
+}
+
+// ExpandHome replaces a leading "~" in path with the user's home directory.
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(homeDir, path[1:])
+}
+
+func (c *Config) overlay(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("could not read config %s: %w", path, err)
+	}
+
+	forges := maps.Clone(c.Links.Forges)
+	if err := json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("could not parse config %s: %w", path, err)
+	}
+
+	// Map entries are decoded from scratch, so keep the templates a forge
+	// entry leaves out
+	for name, templates := range c.Links.Forges {
+		previous, ok := forges[name]
+		if !ok {
+			continue
+		}
+		if templates.Repo == "" {
+			templates.Repo = previous.Repo
+		}
+		if templates.Compare == "" {
+			templates.Compare = previous.Compare
+		}
+		c.Links.Forges[name] = templates
+	}
+	return nil
+}
+
+func defaultHistoryPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".claude", "cstatus", "history.jsonl")
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cstatus.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOverlayReplacesLists(t *testing.T) {
+	global := writeConfig(t, `{
+		"limit": {"thresholds": [{"above": 90, "bg": "#ff0000"}]},
+		"freshness": {"thresholds": [{"above": 3, "bg": "#111111"}, {"above": 9, "fg": "#eeeeee"}]},
+		"git": {"branch": {"replace": [{"pattern": "^feature/", "with": "f/"}, {"pattern": "^bugfix/", "with": "b/"}]}}
+	}`)
+	project := writeConfig(t, `{
+		"git": {"branch": {"replace": [{"pattern": "^release/"}]}}
+	}`)
+
+	cfg, err := LoadFiles(global, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fields a list element leaves out stay empty rather than coming from
+	// the element it replaced
+	if want := (Thresholds{{Above: 90, Bg: "#ff0000"}}); !reflect.DeepEqual(cfg.Limit.Thresholds, want) {
+		t.Errorf("limit thresholds = %+v, want %+v", cfg.Limit.Thresholds, want)
+	}
+	if want := (Thresholds{{Above: 3, Bg: "#111111"}, {Above: 9, Fg: "#eeeeee"}}); !reflect.DeepEqual(cfg.Freshness.Thresholds, want) {
+		t.Errorf("freshness thresholds = %+v, want %+v", cfg.Freshness.Thresholds, want)
+	}
+	if want := (Replacements{{Pattern: "^release/"}}); !reflect.DeepEqual(cfg.Git.Branch.Replace, want) {
+		t.Errorf("branch replacements = %+v, want %+v", cfg.Git.Branch.Replace, want)
+	}
+
+	// Lists a file leaves out keep their previous value
+	if want := Default().Diff.Thresholds; !reflect.DeepEqual(cfg.Diff.Thresholds, want) {
+		t.Errorf("diff thresholds = %+v, want the defaults %+v", cfg.Diff.Thresholds, want)
+	}
+}
+
+func TestOverlayMergesForgeTemplates(t *testing.T) {
+	path := writeConfig(t, `{
+		"links": {
+			"hosts": {"git.example.com": "forgejo"},
+			"forges": {
+				"github": {"compare": "https://{host}/{owner}/{repo}/compare/{branch}"},
+				"forgejo": {"repo": "https://{host}/{owner}/{repo}"}
+			}
+		}
+	}`)
+
+	cfg, err := LoadFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defaults := Default().Links.Forges
+	want := ForgeTemplates{
+		Repo:    defaults["github"].Repo,
+		Compare: "https://{host}/{owner}/{repo}/compare/{branch}",
+	}
+	if got := cfg.Links.Forges["github"]; got != want {
+		t.Errorf("github templates = %+v, want %+v", got, want)
+	}
+	if got := cfg.Links.Forges["gitlab"]; got != defaults["gitlab"] {
+		t.Errorf("gitlab templates = %+v, want the defaults", got)
+	}
+	if got := cfg.Links.Forges["forgejo"]; got != (ForgeTemplates{Repo: "https://{host}/{owner}/{repo}"}) {
+		t.Errorf("forgejo templates = %+v", got)
+	}
+}
@@ -0,0 +1,32 @@
+package git
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/CS-5/cstatus/cache"
+)
+
+// loadCache decodes the named cache file into v and reports whether it
+// could. Callers start over with an empty cache when it could not.
+func loadCache(name string, v any) bool {
+	path := cache.Dir(name)
+	return path != "" && cache.Read(path, v)
+}
+
+// saveCache replaces the named cache file. Failures are logged but
+// otherwise ignored; caches are only an optimization.
+func saveCache(name string, v any) {
+	path := cache.Dir(name)
+	if path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Printf("Warning: failed to create cache directory: %v", err)
+		return
+	}
+	if err := cache.Write(path, v); err != nil {
+		log.Printf("Warning: failed to save %s: %v", name, err)
+	}
+}
@@ -0,0 +1,124 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emptyTree is the hash of the empty tree, which the working tree is
+// compared against before the first commit.
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// DiffStat summarizes the uncommitted changes in a working tree, staged and
+// unstaged together. Untracked files are not included.
+type DiffStat struct {
+	Files   int
+	Added   int
+	Removed int
+}
+
+// diffStatEntry is a cached DiffStat and the state it was computed from.
+type diffStatEntry struct {
+	Head         string
+	IndexModTime time.Time
+	IndexSize    int64
+	Exclude      []string
+	Checked      time.Time
+	Stat         DiffStat
+}
+
+// DiffStat returns the diff stat of the working tree against HEAD, leaving
+// out paths matching any of the exclude globs. Results are cached by the
+// index's modification time and HEAD. Editing a file without staging it does
+// not touch the index, so a cached result is also recomputed once it is older
+// than maxAge.
+func (r *Repository) DiffStat(exclude []string, maxAge time.Duration) (*DiffStat, error) {
+	head := emptyTree
+	if h, err := r.Head(); err == nil && h.Commit != "" {
+		head = h.Commit
+	}
+	var indexModTime time.Time
+	var indexSize int64
+	if info, err := os.Stat(filepath.Join(r.GitDir, "index")); err == nil {
+		indexModTime, indexSize = info.ModTime(), info.Size()
+	}
+
+	cache := loadDiffStatCache()
+	now := time.Now()
+	if entry, ok := cache[r.WorkTree]; ok && entry.Head == head &&
+		entry.IndexModTime.Equal(indexModTime) && entry.IndexSize == indexSize &&
+		slices.Equal(entry.Exclude, exclude) && now.Sub(entry.Checked) < maxAge {
+		return &entry.Stat, nil
+	}
+
+	args := []string{"diff", "--numstat", "--no-renames", head, "--"}
+	if len(exclude) > 0 {
+		args = append(args, ".")
+		for _, glob := range exclude {
+			args = append(args, ":(exclude,glob)"+glob)
+		}
+	}
+	output, err := git.Run(r.WorkTree, args...)
+	if err != nil {
+		return nil, err
+	}
+	stat := ParseNumstat(output)
+
+	cache[r.WorkTree] = &diffStatEntry{
+		Head:         head,
+		IndexModTime: indexModTime,
+		IndexSize:    indexSize,
+		Exclude:      exclude,
+		Checked:      now,
+		Stat:         stat,
+	}
+	saveDiffStatCache(cache)
+	return &stat, nil
+}
+
+// ParseNumstat totals the output of `git diff --numstat`. Binary files count
+// as changed files without lines.
+func ParseNumstat(output []byte) DiffStat {
+	var stat DiffStat
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		stat.Files++
+		added, _ := strconv.Atoi(fields[0])
+		removed, _ := strconv.Atoi(fields[1])
+		stat.Added += added
+		stat.Removed += removed
+	}
+	return stat
+}
+
+const diffStatCacheFile = "diffstat.gob"
+
+// loadDiffStatCache reads cached diff stats keyed by working tree. Any
+// problem yields an empty cache.
+func loadDiffStatCache() map[string]*diffStatEntry {
+	cache := map[string]*diffStatEntry{}
+	if !loadCache(diffStatCacheFile, &cache) || cache == nil {
+		return map[string]*diffStatEntry{}
+	}
+	return cache
+}
+
+func saveDiffStatCache(cache map[string]*diffStatEntry) {
+	// Drop working trees that no longer exist
+	for workTree := range cache {
+		if _, err := os.Stat(workTree); err != nil {
+			delete(cache, workTree)
+		}
+	}
+	saveCache(diffStatCacheFile, cache)
+}
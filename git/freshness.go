@@ -0,0 +1,97 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CommitTime reads the committer date of a commit from the object database.
+func (r *Repository) CommitTime(commit string) (time.Time, error) {
+	objects := newObjectStore(r.CommonDir)
+	defer objects.close()
+
+	typ, data, err := objects.read(commit)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if typ != objCommit {
+		return time.Time{}, fmt.Errorf("%s is not a commit", commit)
+	}
+
+	// committer Name <email> 1700000000 +0100
+	committer, ok := headerField(data, "committer")
+	if !ok {
+		return time.Time{}, fmt.Errorf("commit %s has no committer", commit)
+	}
+	fields := strings.Fields(committer[strings.LastIndex(committer, ">")+1:])
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("commit %s has no date", commit)
+	}
+	seconds, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// LastFetch returns when the repository was last fetched, from the
+// modification time of FETCH_HEAD. Git writes FETCH_HEAD to the working
+// tree's own git directory, so a linked worktree only falls back to the main
+// one's if it never fetched itself. It is zero if it was never fetched.
+func (r *Repository) LastFetch() time.Time {
+	for _, dir := range []string{r.GitDir, r.CommonDir} {
+		if info, err := os.Stat(filepath.Join(dir, "FETCH_HEAD")); err == nil {
+			return info.ModTime()
+		}
+	}
+	return time.Time{}
+}
+
+// HasRemotes reports whether any remote is configured.
+func (r *Repository) HasRemotes() bool {
+	data, err := os.ReadFile(filepath.Join(r.CommonDir, "config"))
+	return err == nil && bytes.Contains(data, []byte(`[remote "`))
+}
+
+// BaseRef returns the ref of the branch work is expected to merge into: the
+// origin remote's default branch, falling back to main or master. It returns
+// "" when none exists.
+func (r *Repository) BaseRef() string {
+	candidates := []string{"refs/remotes/origin/main", "refs/remotes/origin/master", "refs/heads/main", "refs/heads/master"}
+	if branch := r.DefaultBranch("origin"); branch != "" {
+		candidates = append([]string{"refs/remotes/origin/" + branch}, candidates...)
+	}
+	for _, ref := range candidates {
+		if _, err := r.ResolveRef(ref); err == nil {
+			return ref
+		}
+	}
+	return ""
+}
+
+// Unpushed counts the commits on HEAD that are on no remote-tracking branch.
+func (r *Repository) Unpushed() (int, error) {
+	return r.countCommits("HEAD", "--not", "--remotes")
+}
+
+// CommitsSince counts the commits on HEAD since it diverged from ref.
+func (r *Repository) CommitsSince(ref string) (int, error) {
+	return r.countCommits(ref + "..HEAD")
+}
+
+func (r *Repository) countCommits(args ...string) (int, error) {
+	dir := r.WorkTree
+	if dir == "" {
+		dir = r.GitDir
+	}
+	output, err := git.Run(dir, append([]string{"rev-list", "--count"}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(output)))
+}
@@ -0,0 +1,45 @@
+package git
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLastFetchInWorktree(t *testing.T) {
+	upstream := newFixture(t)
+	upstream.history(2)
+
+	f := newFixture(t)
+	f.history(1)
+	f.git("remote", "add", "origin", upstream.dir)
+	worktree := filepath.Join(t.TempDir(), "feature")
+	f.git("worktree", "add", "-q", worktree)
+
+	main := f.repository()
+	linked, err := Discover(worktree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !main.LastFetch().IsZero() || !linked.LastFetch().IsZero() {
+		t.Fatal("LastFetch is set before any fetch")
+	}
+
+	// Fetching in the main working tree is the fallback for the linked one
+	f.git("fetch", "-q", "origin")
+	fetched := main.LastFetch()
+	if fetched.IsZero() || !linked.LastFetch().Equal(fetched) {
+		t.Errorf("linked LastFetch = %v, want the main working tree's %v", linked.LastFetch(), fetched)
+	}
+
+	// Once the linked worktree fetches, its own FETCH_HEAD is used
+	time.Sleep(10 * time.Millisecond)
+	f.dir = worktree
+	f.git("fetch", "-q", "origin")
+	if linked.LastFetch().Equal(fetched) {
+		t.Error("linked LastFetch ignores the worktree's own FETCH_HEAD")
+	}
+	if !main.LastFetch().Equal(fetched) {
+		t.Error("fetching in the linked worktree changed the main LastFetch")
+	}
+}
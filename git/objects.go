@@ -0,0 +1,531 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxDescribeDepth bounds how many commits NearestTag visits, so a tagless
+// history costs the same as a short one.
+const maxDescribeDepth = 1000
+
+// Object types as stored in pack files.
+const (
+	objCommit   = 1
+	objTree     = 2
+	objBlob     = 3
+	objTag      = 4
+	objOfsDelta = 6
+	objRefDelta = 7
+)
+
+var objectTypes = map[string]int{"commit": objCommit, "tree": objTree, "blob": objBlob, "tag": objTag}
+
+var errObjectNotFound = errors.New("object not found")
+
+// objectStore reads commits and tags straight from the object database,
+// both loose objects and pack files. Only SHA-1 repositories are supported.
+type objectStore struct {
+	dir   string
+	packs []*packFile
+}
+
+func newObjectStore(commonDir string) *objectStore {
+	store := &objectStore{dir: filepath.Join(commonDir, "objects")}
+	indexes, _ := filepath.Glob(filepath.Join(store.dir, "pack", "*.idx"))
+	for _, index := range indexes {
+		store.packs = append(store.packs, &packFile{
+			indexPath: index,
+			packPath:  strings.TrimSuffix(index, ".idx") + ".pack",
+		})
+	}
+	return store
+}
+
+func (o *objectStore) close() {
+	for _, pack := range o.packs {
+		pack.close()
+	}
+}
+
+// read returns the type and content of the object with the given hash.
+func (o *objectStore) read(hash string) (int, []byte, error) {
+	raw, err := hex.DecodeString(hash)
+	if err != nil || len(raw) != 20 {
+		return 0, nil, fmt.Errorf("unsupported object hash %q", hash)
+	}
+
+	if typ, data, err := o.readLoose(hash); err == nil {
+		return typ, data, nil
+	}
+	for _, pack := range o.packs {
+		offset, err := pack.find(raw)
+		if err != nil {
+			continue
+		}
+		return pack.readAt(offset, o)
+	}
+	return 0, nil, fmt.Errorf("%s: %w", hash, errObjectNotFound)
+}
+
+func (o *objectStore) readLoose(hash string) (int, []byte, error) {
+	file, err := os.Open(filepath.Join(o.dir, hash[:2], hash[2:]))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+
+	reader, err := zlib.NewReader(file)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	header, content, ok := bytes.Cut(data, []byte{0})
+	if !ok {
+		return 0, nil, fmt.Errorf("malformed object %s", hash)
+	}
+	name, _, _ := strings.Cut(string(header), " ")
+	typ, ok := objectTypes[name]
+	if !ok {
+		return 0, nil, fmt.Errorf("unknown object type %q", name)
+	}
+	return typ, content, nil
+}
+
+// peel follows annotated tags to the object they point at. Hashes that
+// cannot be read are returned unchanged.
+func (o *objectStore) peel(hash string) string {
+	for range maxSymrefDepth {
+		typ, data, err := o.read(hash)
+		if err != nil || typ != objTag {
+			return hash
+		}
+		target, ok := headerField(data, "object")
+		if !ok {
+			return hash
+		}
+		hash = target
+	}
+	return hash
+}
+
+// parents returns the parent hashes of a commit.
+func (o *objectStore) parents(hash string) ([]string, error) {
+	typ, data, err := o.read(hash)
+	if err != nil {
+		return nil, err
+	}
+	if typ != objCommit {
+		return nil, fmt.Errorf("%s is not a commit", hash)
+	}
+
+	var parents []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		if parent, ok := strings.CutPrefix(line, "parent "); ok {
+			parents = append(parents, parent)
+		}
+	}
+	return parents, nil
+}
+
+// headerField returns the value of the first header line starting with key.
+func headerField(data []byte, key string) (string, bool) {
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, key+" "); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// tagCacheEntry is a cached NearestTag result and the state it was computed
+// from.
+type tagCacheEntry struct {
+	Commit   string
+	Tags     uint64
+	Tag      string
+	Distance int
+}
+
+const tagCacheFile = "tags.gob"
+
+// NearestTag finds the tag closest to commit in its history, like
+// `git describe --tags --abbrev=0`, and how many commits separate them.
+// It returns "" when no tag is reachable within maxDescribeDepth commits.
+// Results are cached per working tree until HEAD or any tag changes.
+func (r *Repository) NearestTag(commit string) (string, int, error) {
+	state := r.tagsState()
+	cache := map[string]*tagCacheEntry{}
+	if !loadCache(tagCacheFile, &cache) || cache == nil {
+		cache = map[string]*tagCacheEntry{}
+	}
+	if entry, ok := cache[r.GitDir]; ok && entry.Commit == commit && entry.Tags == state {
+		return entry.Tag, entry.Distance, nil
+	}
+
+	tag, distance, err := r.nearestTag(commit)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Drop repositories that no longer exist
+	for gitDir := range cache {
+		if _, err := os.Stat(gitDir); err != nil {
+			delete(cache, gitDir)
+		}
+	}
+	cache[r.GitDir] = &tagCacheEntry{Commit: commit, Tags: state, Tag: tag, Distance: distance}
+	saveCache(tagCacheFile, cache)
+	return tag, distance, nil
+}
+
+func (r *Repository) nearestTag(commit string) (string, int, error) {
+	objects := newObjectStore(r.CommonDir)
+	defer objects.close()
+
+	tags, err := r.tags(objects)
+	if err != nil || len(tags) == 0 {
+		return "", 0, err
+	}
+
+	// Breadth-first, so the first tagged commit found is the closest
+	visited := map[string]bool{commit: true}
+	queue := []string{commit}
+	for depth := 0; len(queue) > 0 && len(visited) <= maxDescribeDepth; depth++ {
+		var next []string
+		for _, hash := range queue {
+			if names := tags[hash]; len(names) > 0 {
+				return newestName(names), depth, nil
+			}
+			parents, err := objects.parents(hash)
+			if err != nil {
+				return "", 0, err
+			}
+			for _, parent := range parents {
+				if !visited[parent] {
+					visited[parent] = true
+					next = append(next, parent)
+				}
+			}
+		}
+		queue = next
+	}
+	return "", 0, nil
+}
+
+// newestName picks one of several tags on the same commit. Version tags
+// usually sort so the greatest is the most recent.
+func newestName(names []string) string {
+	best := names[0]
+	for _, name := range names[1:] {
+		if name > best {
+			best = name
+		}
+	}
+	return best
+}
+
+// packFile reads objects from a pack using its version 2 index. The index is
+// searched on disk rather than loaded, since it can be large.
+type packFile struct {
+	indexPath string
+	packPath  string
+
+	index  *os.File
+	pack   *os.File
+	fanout [256]uint32
+	opened bool
+	err    error
+
+	// bases keeps objects that offset deltas were built on, since
+	// neighbouring commits tend to share the same delta chain
+	bases map[int64]packedObject
+}
+
+type packedObject struct {
+	typ  int
+	data []byte
+}
+
+// maxCachedBases bounds how many delta bases a pack keeps in memory.
+const maxCachedBases = 256
+
+func (p *packFile) open() error {
+	if p.opened {
+		return p.err
+	}
+	p.opened = true
+
+	p.index, p.err = os.Open(p.indexPath)
+	if p.err != nil {
+		return p.err
+	}
+	header := make([]byte, 8+256*4)
+	if _, p.err = p.index.ReadAt(header, 0); p.err != nil {
+		return p.err
+	}
+	if !bytes.Equal(header[:4], []byte{0xff, 't', 'O', 'c'}) || binary.BigEndian.Uint32(header[4:8]) != 2 {
+		p.err = fmt.Errorf("unsupported pack index %s", p.indexPath)
+		return p.err
+	}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(header[8+i*4:])
+	}
+
+	p.pack, p.err = os.Open(p.packPath)
+	return p.err
+}
+
+func (p *packFile) close() {
+	if p.index != nil {
+		p.index.Close()
+	}
+	if p.pack != nil {
+		p.pack.Close()
+	}
+}
+
+// find returns the pack offset of the object with the given raw hash.
+func (p *packFile) find(hash []byte) (int64, error) {
+	if err := p.open(); err != nil {
+		return 0, err
+	}
+
+	const hashesStart = 8 + 256*4
+	total := int64(p.fanout[255])
+	low := int64(0)
+	if hash[0] > 0 {
+		low = int64(p.fanout[hash[0]-1])
+	}
+	high := int64(p.fanout[hash[0]])
+
+	entry := make([]byte, 20)
+	for low < high {
+		mid := (low + high) / 2
+		if _, err := p.index.ReadAt(entry, hashesStart+mid*20); err != nil {
+			return 0, err
+		}
+		switch bytes.Compare(entry, hash) {
+		case 0:
+			return p.offset(mid, total)
+		case -1:
+			low = mid + 1
+		default:
+			high = mid
+		}
+	}
+	return 0, errObjectNotFound
+}
+
+// offset reads the pack offset of the nth object in the index.
+func (p *packFile) offset(n, total int64) (int64, error) {
+	offsetsStart := 8 + 256*4 + total*20 + total*4
+	buf := make([]byte, 8)
+	if _, err := p.index.ReadAt(buf[:4], offsetsStart+n*4); err != nil {
+		return 0, err
+	}
+	offset := binary.BigEndian.Uint32(buf[:4])
+	if offset&0x80000000 == 0 {
+		return int64(offset), nil
+	}
+
+	// Offsets past 2GiB live in a separate table of 64-bit entries
+	largeStart := offsetsStart + total*4
+	if _, err := p.index.ReadAt(buf, largeStart+int64(offset&0x7fffffff)*8); err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(buf)), nil
+}
+
+// readAt reads and, for deltas, reconstructs the object at offset. Bases of
+// ref deltas may live in another pack, so they are looked up through store.
+func (p *packFile) readAt(offset int64, store *objectStore) (int, []byte, error) {
+	reader := bufio.NewReader(io.NewSectionReader(p.pack, offset, 1<<62))
+
+	b, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	// The inflated size follows the type; zlib finds the end on its own
+	typ := int(b>>4) & 7
+	for b&0x80 != 0 {
+		if b, err = reader.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	var baseType int
+	var base []byte
+	switch typ {
+	case objOfsDelta:
+		distance, err := readOffsetDelta(reader)
+		if err != nil {
+			return 0, nil, err
+		}
+		baseType, base, err = p.readBase(offset-distance, store)
+		if err != nil {
+			return 0, nil, err
+		}
+	case objRefDelta:
+		raw := make([]byte, 20)
+		if _, err := io.ReadFull(reader, raw); err != nil {
+			return 0, nil, err
+		}
+		baseType, base, err = store.read(hex.EncodeToString(raw))
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	inflater, err := zlib.NewReader(reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer inflater.Close()
+	data, err := io.ReadAll(inflater)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if base == nil {
+		return typ, data, nil
+	}
+	result, err := applyDelta(base, data)
+	return baseType, result, err
+}
+
+// readBase reads the base of an offset delta, keeping it for other deltas
+// built on it.
+func (p *packFile) readBase(offset int64, store *objectStore) (int, []byte, error) {
+	if base, ok := p.bases[offset]; ok {
+		return base.typ, base.data, nil
+	}
+	typ, data, err := p.readAt(offset, store)
+	if err != nil {
+		return 0, nil, err
+	}
+	if p.bases == nil {
+		p.bases = map[int64]packedObject{}
+	}
+	if len(p.bases) < maxCachedBases {
+		p.bases[offset] = packedObject{typ: typ, data: data}
+	}
+	return typ, data, nil
+}
+
+// readOffsetDelta reads the negative base offset of an offset delta.
+func readOffsetDelta(reader io.ByteReader) (int64, error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	distance := int64(b & 0x7f)
+	for b&0x80 != 0 {
+		if b, err = reader.ReadByte(); err != nil {
+			return 0, err
+		}
+		distance = ((distance + 1) << 7) | int64(b&0x7f)
+	}
+	return distance, nil
+}
+
+// applyDelta rebuilds an object from its base and a git delta.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	errCorrupt := errors.New("corrupt delta")
+
+	readSize := func() (int, bool) {
+		size, shift := 0, 0
+		for len(delta) > 0 {
+			b := delta[0]
+			delta = delta[1:]
+			size |= int(b&0x7f) << shift
+			shift += 7
+			if b&0x80 == 0 {
+				return size, true
+			}
+		}
+		return 0, false
+	}
+
+	baseSize, ok := readSize()
+	if !ok || baseSize != len(base) {
+		return nil, errCorrupt
+	}
+	resultSize, ok := readSize()
+	if !ok {
+		return nil, errCorrupt
+	}
+
+	result := make([]byte, 0, resultSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		if op&0x80 == 0 {
+			// Insert the next op bytes literally
+			n := int(op)
+			if n == 0 || n > len(delta) {
+				return nil, errCorrupt
+			}
+			result = append(result, delta[:n]...)
+			delta = delta[n:]
+			continue
+		}
+
+		// Copy a range of the base; set bits select which bytes follow
+		var offset, size int
+		for i := range 4 {
+			if op&(1<<i) != 0 {
+				if len(delta) == 0 {
+					return nil, errCorrupt
+				}
+				offset |= int(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		for i := range 3 {
+			if op&(0x10<<i) != 0 {
+				if len(delta) == 0 {
+					return nil, errCorrupt
+				}
+				size |= int(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		if size == 0 {
+			size = 0x10000
+		}
+		if offset+size > len(base) {
+			return nil, errCorrupt
+		}
+		result = append(result, base[offset:offset+size]...)
+	}
+
+	if len(result) != resultSize {
+		return nil, errCorrupt
+	}
+	return result, nil
+}
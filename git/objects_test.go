@@ -0,0 +1,313 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fixture is a throwaway repository built with the git binary.
+type fixture struct {
+	t     *testing.T
+	dir   string
+	ticks int
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	// Keep NearestTag's cache out of the user's cache directory
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	f := &fixture{t: t, dir: t.TempDir()}
+	f.git("init", "-q", "-b", "main")
+	return f
+}
+
+func (f *fixture) git(args ...string) string {
+	f.t.Helper()
+	// Fixed, increasing dates keep hashes stable and describe deterministic
+	f.ticks++
+	date := fmt.Sprintf("%d +0000", 1700000000+f.ticks*60)
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = f.dir
+	cmd.Env = append(os.Environ(),
+		"HOME="+f.dir,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com", "GIT_COMMITTER_DATE="+date,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		f.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// commit writes a file that grows with every commit, so packing has
+// something to delta against, and returns the new commit's hash.
+func (f *fixture) commit(n int) string {
+	f.t.Helper()
+	var content strings.Builder
+	for line := range n * 20 {
+		fmt.Fprintf(&content, "line %d of a file that changes a little in every commit\n", line)
+	}
+	if err := os.WriteFile(filepath.Join(f.dir, "file.txt"), []byte(content.String()), 0o644); err != nil {
+		f.t.Fatal(err)
+	}
+	f.git("add", "file.txt")
+	f.git("commit", "-q", "-m", fmt.Sprintf("commit %d", n))
+	return f.git("rev-parse", "HEAD")
+}
+
+// history makes count commits and returns their hashes, oldest first.
+func (f *fixture) history(count int) []string {
+	f.t.Helper()
+	commits := make([]string, count)
+	for n := range commits {
+		commits[n] = f.commit(n + 1)
+	}
+	return commits
+}
+
+func (f *fixture) repository() *Repository {
+	f.t.Helper()
+	repo, err := Discover(f.dir)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return repo
+}
+
+// describe returns what `git describe --tags` reports for commit, or "" and
+// 0 when no tag is reachable.
+func (f *fixture) describe(commit string) (string, int) {
+	f.t.Helper()
+	cmd := exec.Command("git", "describe", "--tags", "--long", commit)
+	cmd.Dir = f.dir
+	output, err := cmd.Output()
+	if err != nil {
+		return "", 0
+	}
+	described := strings.TrimSpace(string(output))
+	described = described[:strings.LastIndex(described, "-g")]
+	cut := strings.LastIndex(described, "-")
+	distance, err := strconv.Atoi(described[cut+1:])
+	if err != nil {
+		f.t.Fatalf("unexpected describe output %q", output)
+	}
+	return described[:cut], distance
+}
+
+// checkObjects reads every object in the repository and compares it with
+// `git cat-file`.
+func (f *fixture) checkObjects() {
+	f.t.Helper()
+	objects := newObjectStore(f.repository().CommonDir)
+	defer objects.close()
+
+	for _, line := range strings.Split(f.git("rev-list", "--objects", "--all"), "\n") {
+		hash, _, _ := strings.Cut(line, " ")
+		typ, data, err := objects.read(hash)
+		if err != nil {
+			f.t.Errorf("read %s: %v", hash, err)
+			continue
+		}
+		name := f.git("cat-file", "-t", hash)
+		if typ != objectTypes[name] {
+			f.t.Errorf("%s has type %d, want %s", hash, typ, name)
+			continue
+		}
+		want := f.git("cat-file", name, hash)
+		if got := strings.TrimSpace(string(data)); got != want {
+			f.t.Errorf("%s %s differs from cat-file", name, hash)
+		}
+	}
+}
+
+// checkDescribe compares NearestTag with `git describe --tags` for each
+// commit.
+func (f *fixture) checkDescribe(commits []string) {
+	f.t.Helper()
+	repo := f.repository()
+	for _, commit := range commits {
+		tag, distance, err := repo.NearestTag(commit)
+		if err != nil {
+			f.t.Errorf("NearestTag(%.7s): %v", commit, err)
+			continue
+		}
+		wantTag, wantDistance := f.describe(commit)
+		if tag != wantTag || distance != wantDistance {
+			f.t.Errorf("NearestTag(%.7s) = %q, %d, want %q, %d", commit, tag, distance, wantTag, wantDistance)
+		}
+	}
+}
+
+// deltas counts the delta objects of each kind in the repository's packs.
+func (f *fixture) deltas() (ofs, ref int) {
+	f.t.Helper()
+	packs, _ := filepath.Glob(filepath.Join(f.dir, ".git", "objects", "pack", "*.pack"))
+	for _, path := range packs {
+		pack := &packFile{indexPath: strings.TrimSuffix(path, ".pack") + ".idx", packPath: path}
+		for _, line := range strings.Split(f.git("verify-pack", "-v", path), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 7 || !isHash(fields[0]) {
+				continue
+			}
+			offset, _ := strconv.ParseInt(fields[4], 10, 64)
+			if err := pack.open(); err != nil {
+				f.t.Fatal(err)
+			}
+			header := make([]byte, 1)
+			if _, err := pack.pack.ReadAt(header, offset); err != nil {
+				f.t.Fatal(err)
+			}
+			switch int(header[0]>>4) & 7 {
+			case objOfsDelta:
+				ofs++
+			case objRefDelta:
+				ref++
+			}
+		}
+		pack.close()
+	}
+	return ofs, ref
+}
+
+func TestLooseObjects(t *testing.T) {
+	f := newFixture(t)
+	commits := f.history(6)
+	f.git("tag", "v0.1", commits[1])
+	f.git("tag", "-a", "-m", "release", "v0.2", commits[3])
+
+	f.checkObjects()
+	f.checkDescribe(commits)
+}
+
+func TestOffsetDeltas(t *testing.T) {
+	f := newFixture(t)
+	commits := f.history(30)
+	f.git("tag", "-a", "-m", "first", "v1.0", commits[4])
+	f.git("tag", "v1.1", commits[17])
+	f.git("gc", "-q", "--aggressive")
+
+	if ofs, _ := f.deltas(); ofs == 0 {
+		t.Fatal("fixture has no offset deltas")
+	}
+	f.checkObjects()
+	f.checkDescribe(commits)
+}
+
+func TestRefDeltas(t *testing.T) {
+	f := newFixture(t)
+	commits := f.history(30)
+	f.git("tag", "-a", "-m", "first", "v1.0", commits[9])
+	f.git("-c", "repack.useDeltaBaseOffset=false", "repack", "-q", "-a", "-d", "-f")
+
+	if _, ref := f.deltas(); ref == 0 {
+		t.Fatal("fixture has no ref deltas")
+	}
+	f.checkObjects()
+	f.checkDescribe(commits)
+}
+
+func TestPackedTags(t *testing.T) {
+	f := newFixture(t)
+	commits := f.history(8)
+	f.git("tag", "-a", "-m", "annotated", "v1.0", commits[2])
+	f.git("tag", "-a", "-m", "annotated", "v1.1", commits[5])
+	f.git("tag", "v1.0.1", commits[3])
+	f.git("pack-refs", "--all")
+
+	packedRefs := filepath.Join(f.dir, ".git", "packed-refs")
+	data, err := os.ReadFile(packedRefs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "\n^") {
+		t.Fatal("packed-refs has no peel lines")
+	}
+
+	t.Run("peeled", func(t *testing.T) {
+		f.t = t
+		f.checkDescribe(commits)
+	})
+
+	// Older git wrote packed-refs without peel lines, leaving annotated
+	// tags to be peeled through the object database
+	var unpeeled strings.Builder
+	for line := range strings.Lines(string(data)) {
+		if !strings.HasPrefix(line, "^") && !strings.HasPrefix(line, "#") {
+			unpeeled.WriteString(line)
+		}
+	}
+	if err := os.WriteFile(packedRefs, []byte(unpeeled.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Run("unpeeled", func(t *testing.T) {
+		f.t = t
+		f.checkDescribe(commits)
+	})
+}
+
+func TestLooseTagOverridesPacked(t *testing.T) {
+	f := newFixture(t)
+	commits := f.history(8)
+	f.git("tag", "v1.0", commits[1])
+	f.git("tag", "-a", "-m", "annotated", "v2.0", commits[3])
+	f.git("pack-refs", "--all")
+
+	// Moving packed tags writes loose refs that shadow the packed entries
+	f.git("tag", "-f", "v1.0", commits[5])
+	f.git("tag", "-f", "-a", "-m", "moved", "v2.0", commits[6])
+	for _, name := range []string{"v1.0", "v2.0"} {
+		if _, err := os.Stat(filepath.Join(f.dir, ".git", "refs", "tags", name)); err != nil {
+			t.Fatalf("tag %s is not loose: %v", name, err)
+		}
+	}
+	f.checkDescribe(commits)
+}
+
+func TestNearestTagCache(t *testing.T) {
+	f := newFixture(t)
+	commits := f.history(4)
+	f.git("tag", "v1.0", commits[0])
+	repo := f.repository()
+	head := commits[3]
+
+	if tag, distance, _ := repo.NearestTag(head); tag != "v1.0" || distance != 3 {
+		t.Fatalf("NearestTag = %q, %d, want v1.0, 3", tag, distance)
+	}
+
+	// A cached result is served without reading objects
+	objectsDir := filepath.Join(f.dir, ".git", "objects")
+	if err := os.Rename(objectsDir, objectsDir+".moved"); err != nil {
+		t.Fatal(err)
+	}
+	if tag, distance, err := repo.NearestTag(head); err != nil || tag != "v1.0" || distance != 3 {
+		t.Errorf("cached NearestTag = %q, %d, %v, want v1.0, 3", tag, distance, err)
+	}
+	if err := os.Rename(objectsDir+".moved", objectsDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// New tags and new commits invalidate it
+	f.git("tag", "v1.1", commits[2])
+	if tag, distance, _ := repo.NearestTag(head); tag != "v1.1" || distance != 1 {
+		t.Errorf("NearestTag after tagging = %q, %d, want v1.1, 1", tag, distance)
+	}
+	if tag, distance, _ := repo.NearestTag(commits[1]); tag != "v1.0" || distance != 1 {
+		t.Errorf("NearestTag of another commit = %q, %d, want v1.0, 1", tag, distance)
+	}
+	f.git("tag", "-d", "v1.0")
+	if tag, _, _ := repo.NearestTag(commits[1]); tag != "" {
+		t.Errorf("NearestTag after deleting the tag = %q, want none", tag)
+	}
+}
@@ -0,0 +1,92 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Operation is a multi-step command left in progress in the working tree,
+// such as a rebase stopped on a conflict.
+type Operation struct {
+	// Name is a short label such as "REBASE-i" or "MERGE"
+	Name string
+	// Step and Total give progress through a rebase or am, zero otherwise
+	Step  int
+	Total int
+	// Branch is the branch being rebased, since HEAD is detached meanwhile
+	Branch string
+}
+
+// String renders the operation as e.g. "REBASE-i 3/7".
+func (o *Operation) String() string {
+	if o.Total == 0 {
+		return o.Name
+	}
+	return o.Name + " " + strconv.Itoa(o.Step) + "/" + strconv.Itoa(o.Total)
+}
+
+// Operation detects the operation in progress from the state files git keeps
+// in the git directory. It returns nil when none is in progress. The checks
+// follow the order git's own prompt script uses.
+func (r *Repository) Operation() *Operation {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(r.GitDir, name))
+		return err == nil
+	}
+
+	if exists("rebase-merge") {
+		op := &Operation{Name: "REBASE"}
+		if exists("rebase-merge/interactive") {
+			op.Name = "REBASE-i"
+		}
+		op.Step = r.readInt("rebase-merge/msgnum")
+		op.Total = r.readInt("rebase-merge/end")
+		op.Branch = r.readBranch("rebase-merge/head-name")
+		return op
+	}
+
+	if exists("rebase-apply") {
+		op := &Operation{Name: "AM/REBASE"}
+		switch {
+		case exists("rebase-apply/rebasing"):
+			op.Name = "REBASE"
+			op.Branch = r.readBranch("rebase-apply/head-name")
+		case exists("rebase-apply/applying"):
+			op.Name = "AM"
+		}
+		op.Step = r.readInt("rebase-apply/next")
+		op.Total = r.readInt("rebase-apply/last")
+		return op
+	}
+
+	switch {
+	case exists("MERGE_HEAD"):
+		return &Operation{Name: "MERGE"}
+	case exists("CHERRY_PICK_HEAD"):
+		return &Operation{Name: "CHERRY-PICK"}
+	case exists("REVERT_HEAD"):
+		return &Operation{Name: "REVERT"}
+	case exists("BISECT_LOG"):
+		return &Operation{Name: "BISECT"}
+	}
+	return nil
+}
+
+func (r *Repository) readInt(name string) int {
+	data, err := os.ReadFile(filepath.Join(r.GitDir, name))
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return n
+}
+
+func (r *Repository) readBranch(name string) string {
+	data, err := os.ReadFile(filepath.Join(r.GitDir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(data)), "refs/heads/")
+}
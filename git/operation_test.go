@@ -0,0 +1,147 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOperation(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  *Operation
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "interactive rebase",
+			files: map[string]string{
+				"rebase-merge/interactive": "",
+				"rebase-merge/msgnum":      "3\n",
+				"rebase-merge/end":         "7\n",
+				"rebase-merge/head-name":   "refs/heads/feature/login\n",
+			},
+			want: &Operation{Name: "REBASE-i", Step: 3, Total: 7, Branch: "feature/login"},
+		},
+		{
+			name: "merge backend rebase",
+			files: map[string]string{
+				"rebase-merge/msgnum":    "1\n",
+				"rebase-merge/end":       "2\n",
+				"rebase-merge/head-name": "refs/heads/main\n",
+			},
+			want: &Operation{Name: "REBASE", Step: 1, Total: 2, Branch: "main"},
+		},
+		{
+			name: "apply backend rebase",
+			files: map[string]string{
+				"rebase-apply/rebasing":  "",
+				"rebase-apply/next":      "2\n",
+				"rebase-apply/last":      "5\n",
+				"rebase-apply/head-name": "refs/heads/fix\n",
+			},
+			want: &Operation{Name: "REBASE", Step: 2, Total: 5, Branch: "fix"},
+		},
+		{
+			name: "am",
+			files: map[string]string{
+				"rebase-apply/applying": "",
+				"rebase-apply/next":     "1\n",
+				"rebase-apply/last":     "4\n",
+			},
+			want: &Operation{Name: "AM", Step: 1, Total: 4},
+		},
+		{
+			name:  "am or rebase",
+			files: map[string]string{"rebase-apply/next": "1\n"},
+			want:  &Operation{Name: "AM/REBASE", Step: 1},
+		},
+		{
+			name:  "merge",
+			files: map[string]string{"MERGE_HEAD": "8c3e0a9d1f4b27e65a0c9d3b1e7f2a4c6d8e0b1f\n"},
+			want:  &Operation{Name: "MERGE"},
+		},
+		{
+			name:  "cherry-pick",
+			files: map[string]string{"CHERRY_PICK_HEAD": "8c3e0a9d1f4b27e65a0c9d3b1e7f2a4c6d8e0b1f\n"},
+			want:  &Operation{Name: "CHERRY-PICK"},
+		},
+		{
+			name:  "revert",
+			files: map[string]string{"REVERT_HEAD": "8c3e0a9d1f4b27e65a0c9d3b1e7f2a4c6d8e0b1f\n"},
+			want:  &Operation{Name: "REVERT"},
+		},
+		{
+			name:  "bisect",
+			files: map[string]string{"BISECT_LOG": "git bisect start\n"},
+			want:  &Operation{Name: "BISECT"},
+		},
+		{
+			name: "rebase stopped on a merge conflict",
+			files: map[string]string{
+				"rebase-merge/msgnum": "2\n",
+				"rebase-merge/end":    "3\n",
+				"MERGE_HEAD":          "8c3e0a9d1f4b27e65a0c9d3b1e7f2a4c6d8e0b1f\n",
+			},
+			want: &Operation{Name: "REBASE", Step: 2, Total: 3},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gitDir := t.TempDir()
+			for name, content := range test.files {
+				path := filepath.Join(gitDir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got := (&Repository{GitDir: gitDir}).Operation()
+			if test.want == nil {
+				if got != nil {
+					t.Errorf("Operation() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil || *got != *test.want {
+				t.Errorf("Operation() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestOperationString(t *testing.T) {
+	tests := []struct {
+		op   Operation
+		want string
+	}{
+		{Operation{Name: "REBASE-i", Step: 3, Total: 7}, "REBASE-i 3/7"},
+		{Operation{Name: "MERGE"}, "MERGE"},
+	}
+	for _, test := range tests {
+		if got := test.op.String(); got != test.want {
+			t.Errorf("%+v.String() = %q, want %q", test.op, got, test.want)
+		}
+	}
+}
+
+// TestOperationDuringRebase checks the state files the local git writes.
+func TestOperationDuringRebase(t *testing.T) {
+	f := newFixture(t)
+	f.history(3)
+	f.git("checkout", "-q", "-b", "feature")
+	// Stop at the first of the two commits being replayed
+	f.git("-c", "sequence.editor=sed -i.orig 1s/^pick/edit/", "rebase", "-q", "-i", "HEAD~2")
+
+	got := f.repository().Operation()
+	want := &Operation{Name: "REBASE-i", Step: 1, Total: 2, Branch: "feature"}
+	if got == nil || *got != *want {
+		t.Errorf("Operation() = %+v, want %+v", got, want)
+	}
+}
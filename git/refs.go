@@ -0,0 +1,213 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymrefDepth bounds how many symbolic refs are followed, guarding
+// against cycles.
+const maxSymrefDepth = 5
+
+// Head is what HEAD points to, read from the git directory without running git.
+type Head struct {
+	// Branch is empty when HEAD is detached
+	Branch   string
+	Detached bool
+	// Commit is empty on a branch with no commits yet
+	Commit string
+}
+
+// ShortCommit returns the abbreviated hash of HEAD.
+func (h *Head) ShortCommit() string {
+	if len(h.Commit) > 7 {
+		return h.Commit[:7]
+	}
+	return h.Commit
+}
+
+// Head reads HEAD and resolves it to a commit.
+func (r *Repository) Head() (*Head, error) {
+	data, err := os.ReadFile(filepath.Join(r.GitDir, "HEAD"))
+	if err != nil {
+		return nil, err
+	}
+	content := strings.TrimSpace(string(data))
+
+	target, ok := strings.CutPrefix(content, "ref: ")
+	if !ok {
+		if !isHash(content) {
+			return nil, fmt.Errorf("invalid HEAD %q", content)
+		}
+		return &Head{Detached: true, Commit: content}, nil
+	}
+
+	head := &Head{Branch: strings.TrimPrefix(target, "refs/heads/")}
+	commit, err := r.ResolveRef(target)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	head.Commit = commit
+	return head, nil
+}
+
+// ResolveRef resolves a full ref name such as "refs/heads/main" to a hash,
+// following symbolic refs. Loose refs are checked before packed-refs.
+func (r *Repository) ResolveRef(name string) (string, error) {
+	for range maxSymrefDepth {
+		value, err := r.readRef(name)
+		if err != nil {
+			return "", err
+		}
+
+		target, ok := strings.CutPrefix(value, "ref: ")
+		if !ok {
+			return value, nil
+		}
+		name = target
+	}
+	return "", fmt.Errorf("too many levels of symbolic refs resolving %s", name)
+}
+
+func (r *Repository) readRef(name string) (string, error) {
+	// Refs such as HEAD and refs/bisect are per worktree; the rest are shared
+	for _, dir := range []string{r.GitDir, r.CommonDir} {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err == nil {
+			return strings.TrimSpace(string(data)), nil
+		}
+	}
+
+	refs, err := r.packedRefs()
+	if err != nil {
+		return "", err
+	}
+	if ref, ok := refs[name]; ok {
+		return ref.hash, nil
+	}
+	return "", fmt.Errorf("ref %s: %w", name, fs.ErrNotExist)
+}
+
+// packedRef is an entry in packed-refs. Peeled holds the commit an annotated
+// tag points to, when git recorded it.
+type packedRef struct {
+	hash   string
+	peeled string
+}
+
+func (r *Repository) packedRefs() (map[string]packedRef, error) {
+	refs := map[string]packedRef{}
+
+	file, err := os.Open(filepath.Join(r.CommonDir, "packed-refs"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return refs, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var last string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "" || line[0] == '#':
+			continue
+		case line[0] == '^':
+			if ref, ok := refs[last]; ok {
+				ref.peeled = line[1:]
+				refs[last] = ref
+			}
+		default:
+			hash, name, ok := strings.Cut(line, " ")
+			if ok {
+				refs[name] = packedRef{hash: hash}
+				last = name
+			}
+		}
+	}
+	return refs, scanner.Err()
+}
+
+// tags maps commit hashes to the names of tags pointing at them. Annotated
+// tags are peeled to their commit.
+func (r *Repository) tags(objects *objectStore) (map[string][]string, error) {
+	packed, err := r.packedRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	refs := map[string]packedRef{}
+	for name, ref := range packed {
+		if strings.HasPrefix(name, "refs/tags/") {
+			refs[name] = ref
+		}
+	}
+
+	// Loose tags override packed ones of the same name
+	tagsDir := filepath.Join(r.CommonDir, "refs", "tags")
+	filepath.WalkDir(tagsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(r.CommonDir, path)
+		refs[filepath.ToSlash(rel)] = packedRef{hash: strings.TrimSpace(string(data))}
+		return nil
+	})
+
+	tags := map[string][]string{}
+	for name, ref := range refs {
+		commit := ref.peeled
+		if commit == "" {
+			commit = objects.peel(ref.hash)
+		}
+		tags[commit] = append(tags[commit], strings.TrimPrefix(name, "refs/tags/"))
+	}
+	return tags, nil
+}
+
+// tagsState fingerprints the tag refs from the size and modification time
+// of packed-refs and the name and target of every loose tag, so it changes
+// whenever a tag is created, moved or deleted.
+func (r *Repository) tagsState() uint64 {
+	h := fnv.New64a()
+	if info, err := os.Stat(filepath.Join(r.CommonDir, "packed-refs")); err == nil {
+		fmt.Fprintf(h, "%d %d\n", info.Size(), info.ModTime().UnixNano())
+	}
+	filepath.WalkDir(filepath.Join(r.CommonDir, "refs", "tags"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(h, "%s %s\n", path, bytes.TrimSpace(data))
+		return nil
+	})
+	return h.Sum64()
+}
+
+func isHash(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Remote identifies a repository on a forge, parsed from a remote URL.
+type Remote struct {
+	// Host includes the port for HTTP remotes, e.g. "git.example.com:8443"
+	Host string
+	// Owner is the user or organization, and may contain slashes for
+	// nested GitLab groups
+	Owner string
+	Repo  string
+}
+
+// ParseRemoteURL parses the SSH and HTTP forms of a remote URL:
+//
+//	https://github.com/owner/repo.git
+//	ssh://git@github.com:22/owner/repo.git
+//	git@github.com:owner/repo.git
+func ParseRemoteURL(rawURL string) (*Remote, error) {
+	rawURL = strings.TrimSpace(rawURL)
+
+	var host, path string
+	if strings.Contains(rawURL, "://") {
+		parsed, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		host = parsed.Host
+		// SSH ports say nothing about where the web interface lives
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			host = parsed.Hostname()
+		}
+		path = parsed.Path
+	} else {
+		// scp-like syntax: [user@]host:path
+		address, rest, ok := strings.Cut(rawURL, ":")
+		if !ok {
+			return nil, fmt.Errorf("unrecognized remote URL %q", rawURL)
+		}
+		_, host, _ = strings.Cut(address, "@")
+		if host == "" {
+			host = address
+		}
+		path = rest
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	slash := strings.LastIndex(path, "/")
+	if host == "" || slash <= 0 {
+		return nil, fmt.Errorf("unrecognized remote URL %q", rawURL)
+	}
+	return &Remote{Host: host, Owner: path[:slash], Repo: path[slash+1:]}, nil
+}
+
+// RemoteURL reads the URL of the named remote from the repository config.
+func (r *Repository) RemoteURL(name string) (string, error) {
+	file, err := os.Open(filepath.Join(r.CommonDir, "config"))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	section := fmt.Sprintf(`[remote "%s"]`, name)
+	inSection := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inSection = line == section
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "url") {
+			return strings.Trim(strings.TrimSpace(value), `"`), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("remote %s has no url", name)
+}
+
+// Remote parses the URL of the named remote.
+func (r *Repository) Remote(name string) (*Remote, error) {
+	rawURL, err := r.RemoteURL(name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRemoteURL(rawURL)
+}
+
+// DefaultBranch returns the branch the named remote's HEAD points to, as
+// recorded by clone or `git remote set-head`, or "" if it is unknown.
+func (r *Repository) DefaultBranch(remote string) string {
+	value, err := r.readRef("refs/remotes/" + remote + "/HEAD")
+	if err != nil {
+		return ""
+	}
+	target, ok := strings.CutPrefix(value, "ref: refs/remotes/"+remote+"/")
+	if !ok {
+		return ""
+	}
+	return target
+}
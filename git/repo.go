@@ -0,0 +1,154 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotRepository is returned by Discover when no repository contains dir.
+var ErrNotRepository = errors.New("not a git repository")
+
+// Repository describes where a repository lives on disk.
+type Repository struct {
+	// WorkTree is the top of the working tree, empty for bare repositories
+	WorkTree string
+	// GitDir holds HEAD and the index for this working tree. For linked
+	// worktrees and submodules it lives outside WorkTree.
+	GitDir string
+	// CommonDir holds the objects and refs shared by all worktrees
+	CommonDir string
+
+	Bare      bool
+	Submodule bool
+	// Worktree is the name of a linked worktree, empty for the main one
+	Worktree string
+}
+
+// Discover finds the repository containing dir the way git does: GIT_DIR
+// and GIT_WORK_TREE take precedence, otherwise each parent is checked for a
+// .git directory, a .git file pointing elsewhere (worktrees and submodules),
+// or for being a bare repository itself. The search stops at any directory
+// listed in GIT_CEILING_DIRECTORIES.
+func Discover(dir string) (*Repository, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if gitDir := os.Getenv("GIT_DIR"); gitDir != "" {
+		if !filepath.IsAbs(gitDir) {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return nil, err
+			}
+			gitDir = filepath.Join(cwd, gitDir)
+		}
+		workTree := os.Getenv("GIT_WORK_TREE")
+		if workTree == "" {
+			workTree = dir
+		}
+		return newRepository(workTree, gitDir), nil
+	}
+
+	ceilings := map[string]bool{}
+	for _, ceiling := range filepath.SplitList(os.Getenv("GIT_CEILING_DIRECTORIES")) {
+		if ceiling != "" {
+			ceilings[filepath.Clean(ceiling)] = true
+		}
+	}
+
+	for current := dir; ; {
+		dotGit := filepath.Join(current, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() && isGitDir(dotGit) {
+				return newRepository(current, dotGit), nil
+			}
+			if !info.IsDir() {
+				if gitDir, err := readGitFile(dotGit); err == nil {
+					return newRepository(current, gitDir), nil
+				}
+			}
+		}
+
+		if isGitDir(current) {
+			repo := newRepository("", current)
+			repo.Bare = true
+			return repo, nil
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current || ceilings[parent] {
+			return nil, ErrNotRepository
+		}
+		current = parent
+	}
+}
+
+func newRepository(workTree, gitDir string) *Repository {
+	repo := &Repository{
+		WorkTree:  workTree,
+		GitDir:    gitDir,
+		CommonDir: gitDir,
+	}
+
+	// Linked worktrees point back at the main repository's git directory
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		repo.CommonDir = filepath.Clean(commonDir)
+		if filepath.Base(filepath.Dir(gitDir)) == "worktrees" {
+			repo.Worktree = filepath.Base(gitDir)
+		}
+	}
+
+	// Submodule git directories live under the superproject's .git/modules
+	if repo.CommonDir == gitDir && strings.Contains(filepath.ToSlash(gitDir), "/.git/modules/") {
+		repo.Submodule = true
+	}
+
+	return repo
+}
+
+// isGitDir reports whether dir looks like a git directory. Linked worktree
+// directories have no objects or refs of their own but name a common dir.
+func isGitDir(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(dir, "commondir")); err == nil {
+		return true
+	}
+	for _, name := range []string{"objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// readGitFile resolves a .git file of the form "gitdir: <path>".
+func readGitFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return "", ErrNotRepository
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	gitDir = filepath.Clean(gitDir)
+
+	if !isGitDir(gitDir) {
+		return "", ErrNotRepository
+	}
+	return gitDir, nil
+}
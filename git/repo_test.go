@@ -0,0 +1,145 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// checkPath fails unless got and want name the same directory; git may
+// resolve symlinks in the paths it writes.
+func checkPath(t *testing.T, name, got, want string) {
+	t.Helper()
+	gotInfo, gotErr := os.Stat(got)
+	wantInfo, wantErr := os.Stat(want)
+	if gotErr != nil || wantErr != nil || !os.SameFile(gotInfo, wantInfo) {
+		t.Errorf("%s = %q, want %q", name, got, want)
+	}
+}
+
+func TestDiscoverSubdirectory(t *testing.T) {
+	f := newFixture(t)
+	f.commit(1)
+	nested := filepath.Join(f.dir, "a", "b")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := Discover(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPath(t, "WorkTree", repo.WorkTree, f.dir)
+	checkPath(t, "GitDir", repo.GitDir, filepath.Join(f.dir, ".git"))
+	checkPath(t, "CommonDir", repo.CommonDir, filepath.Join(f.dir, ".git"))
+	if repo.Bare || repo.Submodule || repo.Worktree != "" {
+		t.Errorf("Discover() = %+v, want a plain repository", repo)
+	}
+}
+
+func TestDiscoverLinkedWorktree(t *testing.T) {
+	f := newFixture(t)
+	f.commit(1)
+	worktree := filepath.Join(t.TempDir(), "feature")
+	f.git("worktree", "add", "-q", "-b", "feature", worktree)
+
+	repo, err := Discover(worktree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPath(t, "WorkTree", repo.WorkTree, worktree)
+	checkPath(t, "GitDir", repo.GitDir, filepath.Join(f.dir, ".git", "worktrees", "feature"))
+	checkPath(t, "CommonDir", repo.CommonDir, filepath.Join(f.dir, ".git"))
+	if repo.Worktree != "feature" {
+		t.Errorf("Worktree = %q, want %q", repo.Worktree, "feature")
+	}
+	if repo.Bare || repo.Submodule {
+		t.Errorf("Discover() = %+v, want a linked worktree", repo)
+	}
+}
+
+func TestDiscoverSubmodule(t *testing.T) {
+	library := newFixture(t)
+	library.commit(1)
+	f := newFixture(t)
+	f.commit(1)
+	f.git("-c", "protocol.file.allow=always", "submodule", "add", "-q", library.dir, "lib")
+
+	repo, err := Discover(filepath.Join(f.dir, "lib"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPath(t, "WorkTree", repo.WorkTree, filepath.Join(f.dir, "lib"))
+	checkPath(t, "GitDir", repo.GitDir, filepath.Join(f.dir, ".git", "modules", "lib"))
+	if !repo.Submodule {
+		t.Errorf("Discover() = %+v, want a submodule", repo)
+	}
+}
+
+func TestDiscoverGitDir(t *testing.T) {
+	f := newFixture(t)
+	f.commit(1)
+	elsewhere := t.TempDir()
+	t.Setenv("GIT_DIR", filepath.Join(f.dir, ".git"))
+
+	repo, err := Discover(elsewhere)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPath(t, "GitDir", repo.GitDir, filepath.Join(f.dir, ".git"))
+	// Without GIT_WORK_TREE git treats the current directory as the top
+	checkPath(t, "WorkTree", repo.WorkTree, elsewhere)
+
+	t.Setenv("GIT_WORK_TREE", f.dir)
+	repo, err = Discover(elsewhere)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPath(t, "WorkTree", repo.WorkTree, f.dir)
+}
+
+func TestDiscoverBare(t *testing.T) {
+	f := newFixture(t)
+	f.commit(1)
+	bare := filepath.Join(t.TempDir(), "bare.git")
+	f.git("clone", "-q", "--bare", f.dir, bare)
+
+	repo, err := Discover(filepath.Join(bare, "refs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !repo.Bare || repo.WorkTree != "" {
+		t.Errorf("Discover() = %+v, want a bare repository", repo)
+	}
+	checkPath(t, "GitDir", repo.GitDir, bare)
+}
+
+func TestDiscoverCeilingDirectories(t *testing.T) {
+	f := newFixture(t)
+	f.commit(1)
+	ceiling := filepath.Join(f.dir, "a")
+	nested := filepath.Join(ceiling, "b")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GIT_CEILING_DIRECTORIES", ceiling)
+	if _, err := Discover(nested); !errors.Is(err, ErrNotRepository) {
+		t.Errorf("Discover() below a ceiling = %v, want ErrNotRepository", err)
+	}
+
+	// A ceiling above the repository does not hide it
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(f.dir))
+	if _, err := Discover(nested); err != nil {
+		t.Errorf("Discover() below the repository's parent: %v", err)
+	}
+}
+
+func TestDiscoverOutsideRepository(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir))
+	if _, err := Discover(dir); !errors.Is(err, ErrNotRepository) {
+		t.Errorf("Discover() = %v, want ErrNotRepository", err)
+	}
+}
@@ -0,0 +1,119 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/CS-5/cstatus/vcs"
+)
+
+var git = vcs.Tool{Name: "git"}
+
+// GitStatus is the state of a working tree as reported by
+// `git status --porcelain=v2 --branch`.
+type GitStatus struct {
+	// Branch is empty when HEAD is detached
+	Branch   string
+	Detached bool
+	// Commit is the full hash of HEAD, empty before the first commit
+	Commit string
+
+	Upstream string
+	Ahead    int
+	Behind   int
+
+	Staged     int
+	Unstaged   int
+	Untracked  int
+	Conflicted int
+	Stashes    int
+}
+
+// IsDirty reports whether the working tree or index has any changes.
+func (s *GitStatus) IsDirty() bool {
+	return s.Staged > 0 || s.Unstaged > 0 || s.Untracked > 0 || s.Conflicted > 0
+}
+
+// ShortCommit returns the abbreviated hash of HEAD.
+func (s *GitStatus) ShortCommit() string {
+	if len(s.Commit) > 7 {
+		return s.Commit[:7]
+	}
+	return s.Commit
+}
+
+// ReadStatus runs git status in dir and parses the result.
+func ReadStatus(dir string) (*GitStatus, error) {
+	output, err := git.Run(dir, "status", "--porcelain=v2", "--branch", "--show-stash")
+	if err != nil {
+		return nil, err
+	}
+	return ParseStatus(output), nil
+}
+
+// ParseStatus parses the output of
+// `git status --porcelain=v2 --branch --show-stash`.
+func ParseStatus(output []byte) *GitStatus {
+	status := &GitStatus{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "#":
+			status.parseHeader(fields[1:])
+		case "1", "2":
+			// Ordinary and renamed entries: XY holds the index and worktree states
+			if len(fields) > 1 && len(fields[1]) == 2 {
+				if fields[1][0] != '.' {
+					status.Staged++
+				}
+				if fields[1][1] != '.' {
+					status.Unstaged++
+				}
+			}
+		case "u":
+			status.Conflicted++
+		case "?":
+			status.Untracked++
+		}
+	}
+
+	return status
+}
+
+func (s *GitStatus) parseHeader(fields []string) {
+	if len(fields) < 2 {
+		return
+	}
+
+	switch fields[0] {
+	case "branch.oid":
+		if fields[1] != "(initial)" {
+			s.Commit = fields[1]
+		}
+	case "branch.head":
+		if fields[1] == "(detached)" {
+			s.Detached = true
+		} else {
+			s.Branch = fields[1]
+		}
+	case "branch.upstream":
+		s.Upstream = fields[1]
+	case "branch.ab":
+		if len(fields) == 3 {
+			s.Ahead, _ = strconv.Atoi(strings.TrimPrefix(fields[1], "+"))
+			s.Behind, _ = strconv.Atoi(strings.TrimPrefix(fields[2], "-"))
+		}
+	case "stash":
+		s.Stashes, _ = strconv.Atoi(fields[1])
+	}
+}
@@ -0,0 +1,67 @@
+package git
+
+import "testing"
+
+func TestParseStatus(t *testing.T) {
+	const commit = "8c3e0a9d1f4b27e65a0c9d3b1e7f2a4c6d8e0b1f"
+	tests := []struct {
+		name   string
+		output string
+		want   GitStatus
+	}{
+		{
+			name: "initial commit",
+			output: "# branch.oid (initial)\n" +
+				"# branch.head main\n" +
+				"1 A. N... 000000 100644 100644 0000000000000000000000000000000000000000 e69de29bb2d1d6434b8b29ae775ad8c2e48c5391 README.md\n",
+			want: GitStatus{Branch: "main", Staged: 1},
+		},
+		{
+			name: "detached HEAD",
+			output: "# branch.oid " + commit + "\n" +
+				"# branch.head (detached)\n",
+			want: GitStatus{Detached: true, Commit: commit},
+		},
+		{
+			name: "ahead and behind upstream",
+			output: "# branch.oid " + commit + "\n" +
+				"# branch.head feature/login\n" +
+				"# branch.upstream origin/feature/login\n" +
+				"# branch.ab +3 -12\n",
+			want: GitStatus{Branch: "feature/login", Commit: commit, Upstream: "origin/feature/login", Ahead: 3, Behind: 12},
+		},
+		{
+			name: "rename",
+			output: "# branch.oid " + commit + "\n" +
+				"# branch.head main\n" +
+				"2 RM N... 100644 100644 100644 3b18e512dba79e4c8300dd08aeb37f8e728b8dad 3b18e512dba79e4c8300dd08aeb37f8e728b8dad R100 new name.go\told name.go\n",
+			want: GitStatus{Branch: "main", Commit: commit, Staged: 1, Unstaged: 1},
+		},
+		{
+			name: "changes of every kind",
+			output: "# branch.oid " + commit + "\n" +
+				"# branch.head main\n" +
+				"# stash 2\n" +
+				"1 .M N... 100644 100644 100644 3b18e512dba79e4c8300dd08aeb37f8e728b8dad 3b18e512dba79e4c8300dd08aeb37f8e728b8dad main.go\n" +
+				"1 M. N... 100644 100644 100644 3b18e512dba79e4c8300dd08aeb37f8e728b8dad 9daeafb9864cf43055ae93beb0afd6c7d144bfa4 go.mod\n" +
+				"u UU N... 100644 100644 100644 100644 3b18e512dba79e4c8300dd08aeb37f8e728b8dad 9daeafb9864cf43055ae93beb0afd6c7d144bfa4 e69de29bb2d1d6434b8b29ae775ad8c2e48c5391 conflict.go\n" +
+				"? notes.txt\n" +
+				"? scratch/\n",
+			want: GitStatus{Branch: "main", Commit: commit, Staged: 1, Unstaged: 1, Untracked: 2, Conflicted: 1, Stashes: 2},
+		},
+		{
+			name:   "empty output",
+			output: "",
+			want:   GitStatus{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ParseStatus([]byte(test.output))
+			if *got != test.want {
+				t.Errorf("ParseStatus() = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package hg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CS-5/cstatus/vcs"
+)
+
+// hg runs with HGPLAIN so user aliases and colors cannot change its output.
+var hg = vcs.Tool{Name: "hg", Env: []string{"HGPLAIN=1"}}
+
+// ErrNotRepository is returned by Discover when no repository contains dir.
+var ErrNotRepository = errors.New("not a mercurial repository")
+
+// HgStatus is the state of a Mercurial working directory.
+type HgStatus struct {
+	Branch string
+	// Bookmark is the active bookmark, empty when none is active
+	Bookmark string
+	Dirty    bool
+	// DirtyKnown is false when hg is not installed or failed, in which case
+	// Dirty is false whether or not there are changes
+	DirtyKnown bool
+}
+
+// Discover returns the root of the repository containing dir: the nearest
+// parent with a .hg directory.
+func Discover(dir string) (string, error) {
+	return vcs.FindRoot(dir, ".hg", ErrNotRepository)
+}
+
+// ReadStatus reads the branch and bookmark of the repository at root and,
+// when hg is installed, whether tracked files have changes.
+func ReadStatus(root string) (*HgStatus, error) {
+	hgDir := filepath.Join(root, ".hg")
+	status := &HgStatus{Branch: "default"}
+
+	// .hg/branch is only written once a branch other than default is used
+	if data, err := os.ReadFile(filepath.Join(hgDir, "branch")); err == nil {
+		if branch := strings.TrimSpace(string(data)); branch != "" {
+			status.Branch = branch
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if data, err := os.ReadFile(filepath.Join(hgDir, "bookmarks.current")); err == nil {
+		status.Bookmark = strings.TrimSpace(string(data))
+	}
+
+	// Modified, added, removed and deleted files; untracked ones are ignored
+	if output, err := hg.Run(root, "status", "--modified", "--added", "--removed", "--deleted", "--quiet"); err == nil {
+		status.Dirty = len(strings.TrimSpace(string(output))) > 0
+		status.DirtyKnown = true
+	}
+	return status, nil
+}
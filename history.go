@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/CS-5/cstatus/claude"
+	"github.com/CS-5/cstatus/config"
+)
+
+// HistoryEntry is a single recorded statusline invocation. History files are
+// JSONL, one entry per line, appended to on every render while recording is enabled.
+type HistoryEntry struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Input     json.RawMessage `json:"input"`
+	Output    string          `json:"output"`
+}
+
+func recordHistory(path string, input []byte, output string) error {
+	if path == "" {
+		return fmt.Errorf("no history path configured")
+	}
+
+	line, err := json.Marshal(HistoryEntry{
+		Timestamp: time.Now(),
+		Input:     bytes.TrimSpace(input),
+		Output:    output,
+	})
+	if err != nil {
+		return fmt.Errorf("could not serialize history entry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("could not create history directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open history file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("could not write history file: %w", err)
+	}
+	return nil
+}
+
+func readHistory(path string) ([]HistoryEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open history file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	reader := bufio.NewReader(file)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			// A crash can leave a partial entry behind; skip it like a bad
+			// transcript line
+			var entry HistoryEntry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				log.Printf("Warning: skipping unreadable history entry: %v", err)
+			} else {
+				entries = append(entries, entry)
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// handleReplay re-renders a recorded history file. Each entry is printed with
+// the line that was recorded at the time and the line rendered now, so changes
+// to the config can be compared against real input.
+//
+// Widgets computed from the input and transcripts see them as they were when
+// the entry was recorded. Widgets reading the repository or working directory,
+// such as git, vcs, freshness and diff, show their current state instead.
+func handleReplay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	configPath := flags.String("config", "", "render with this config file instead of the current config")
+	sessionID := flags.String("session", "", "only replay entries from this session")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	historyPath := flags.Arg(0)
+	if historyPath == "" {
+		cfg, err := config.Load("")
+		if err != nil {
+			return err
+		}
+		historyPath = cfg.History.Path
+	}
+
+	entries, err := readHistory(historyPath)
+	if err != nil {
+		return err
+	}
+
+	replay := claude.NewReplay()
+	for _, entry := range entries {
+		if *sessionID != "" && entrySessionID(entry) != *sessionID {
+			continue
+		}
+
+		claudeContext, err := replay.Context(entry.Input, entry.Timestamp)
+		if err != nil {
+			log.Printf("Warning: could not replay entry from %s: %v", entry.Timestamp.Format(time.RFC3339), err)
+			continue
+		}
+
+		var cfg *config.Config
+		if *configPath != "" {
+			cfg, err = config.LoadFiles(*configPath)
+		} else {
+			cfg, err = config.Load(claudeContext.Code.Workspace.ProjectDir)
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s\n", entry.Timestamp.Local().Format(time.DateTime))
+		fmt.Printf("  recorded: %s\n", entry.Output)
+		fmt.Printf("  replayed: %s\n", renderStatusline(claudeContext, cfg))
+	}
+
+	return nil
+}
+
+// entrySessionID returns the session an entry was recorded in, or "" if its
+// input cannot be read.
+func entrySessionID(entry HistoryEntry) string {
+	var input struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(entry.Input, &input); err != nil {
+		return ""
+	}
+	return input.SessionID
+}
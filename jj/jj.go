@@ -0,0 +1,77 @@
+package jj
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/CS-5/cstatus/vcs"
+)
+
+var jj = vcs.Tool{Name: "jj"}
+
+// ErrNotRepository is returned by Discover when no repository contains dir.
+var ErrNotRepository = errors.New("not a jj repository")
+
+// statusTemplate prints one tab separated line per revision: the shortest
+// unique change ID, local bookmarks, and markers for conflicts and empty
+// changes.
+const statusTemplate = `change_id.shortest(8) ++ "\t" ++ local_bookmarks.join(",") ++ "\t" ++ if(conflict, "conflict") ++ "\t" ++ if(empty, "empty") ++ "\n"`
+
+// JJStatus is the state of the working-copy change.
+type JJStatus struct {
+	ChangeID string
+	// Bookmarks are those on the working-copy change or, failing that, on
+	// its closest ancestors with bookmarks
+	Bookmarks []string
+	Conflict  bool
+	// Empty is true when the working-copy change has no modifications
+	Empty bool
+}
+
+// Discover returns the root of the repository containing dir: the nearest
+// parent with a .jj directory.
+func Discover(dir string) (string, error) {
+	return vcs.FindRoot(dir, ".jj", ErrNotRepository)
+}
+
+// ReadStatus runs jj in root and parses the result. It fails with
+// exec.ErrNotFound when jj is not installed. The working copy is not
+// snapshotted, so changes made since the last jj command are not reflected.
+func ReadStatus(root string) (*JJStatus, error) {
+	output, err := jj.Run(root, "log", "--no-graph", "--ignore-working-copy", "--color", "never",
+		"-r", "@ | heads(::@- & bookmarks())", "-T", statusTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return ParseStatus(output)
+}
+
+// ParseStatus parses the output of ReadStatus's jj log invocation. The
+// working-copy change comes first; any further lines are ancestors with
+// bookmarks.
+func ParseStatus(output []byte) (*JJStatus, error) {
+	lines := strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return nil, errors.New("empty jj log output")
+	}
+
+	status := &JJStatus{}
+	for i, line := range lines {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 4 {
+			continue
+		}
+		bookmarks := strings.FieldsFunc(fields[1], func(r rune) bool { return r == ',' })
+		if i == 0 {
+			status.ChangeID = fields[0]
+			status.Conflict = fields[2] == "conflict"
+			status.Empty = fields[3] == "empty"
+			status.Bookmarks = bookmarks
+			continue
+		}
+		if len(status.Bookmarks) == 0 {
+			status.Bookmarks = append(status.Bookmarks, bookmarks...)
+		}
+	}
+	return status, nil
+}
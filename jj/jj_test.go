@@ -0,0 +1,63 @@
+package jj
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   JJStatus
+	}{
+		{
+			name:   "bookmark on working copy",
+			output: "kxyzqrst\tmain\t\t\n",
+			want:   JJStatus{ChangeID: "kxyzqrst", Bookmarks: []string{"main"}},
+		},
+		{
+			name:   "conflict",
+			output: "kxyzqrst\tmain,feature\tconflict\t\n",
+			want:   JJStatus{ChangeID: "kxyzqrst", Bookmarks: []string{"main", "feature"}, Conflict: true},
+		},
+		{
+			name:   "empty change",
+			output: "wlmn\t\t\tempty\n",
+			want:   JJStatus{ChangeID: "wlmn", Bookmarks: []string{}, Empty: true},
+		},
+		{
+			name:   "ancestor bookmark",
+			output: "wlmn\t\t\tempty\nzkqp\tfeature/JIRA-12\t\t\n",
+			want:   JJStatus{ChangeID: "wlmn", Bookmarks: []string{"feature/JIRA-12"}, Empty: true},
+		},
+		{
+			name:   "working copy bookmark wins over ancestor",
+			output: "wlmn\tdev\t\t\nzkqp\tmain\t\t\n",
+			want:   JJStatus{ChangeID: "wlmn", Bookmarks: []string{"dev"}},
+		},
+		{
+			name:   "malformed ancestor line",
+			output: "wlmn\t\t\t\ngarbage\n",
+			want:   JJStatus{ChangeID: "wlmn", Bookmarks: []string{}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseStatus([]byte(test.output))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, test.want) {
+				t.Errorf("ParseStatus(%q) = %+v, want %+v", test.output, *got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseStatusEmpty(t *testing.T) {
+	if _, err := ParseStatus(nil); err == nil {
+		t.Error("ParseStatus(nil) succeeded, want an error")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"log"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/CS-5/cstatus/config"
+	"github.com/CS-5/cstatus/git"
+	"github.com/CS-5/cstatus/util"
+)
+
+// linker builds the URLs widgets attach to their segments. A nil linker
+// links nothing, so widgets can call it unconditionally.
+type linker struct {
+	cfg config.LinksConfig
+}
+
+// newLinker returns nil when links are disabled or the terminal cannot
+// render them.
+func newLinker(cfg config.LinksConfig) *linker {
+	switch cfg.Mode {
+	case "always":
+	case "never":
+		return nil
+	case "auto", "":
+		if !util.HyperlinksSupported() {
+			return nil
+		}
+	default:
+		log.Printf("Warning: unknown links mode %q", cfg.Mode)
+		return nil
+	}
+	return &linker{cfg: cfg}
+}
+
+// link attaches url to segment when linking is enabled.
+func (l *linker) link(segment *util.Segment, url string) *util.Segment {
+	if l != nil && segment != nil && url != "" {
+		segment.WithLink(url)
+	}
+	return segment
+}
+
+// repoURL returns the web page of the repository's origin remote.
+func (l *linker) repoURL(repo *git.Repository) string {
+	templates, vars := l.forge(repo)
+	return expandTemplate(templates.Repo, vars)
+}
+
+// compareURL returns a comparison of branch against the default branch, or
+// the repository page when branch is the default branch.
+func (l *linker) compareURL(repo *git.Repository, branch string) string {
+	templates, vars := l.forge(repo)
+	if vars == nil || branch == "" {
+		return ""
+	}
+	if branch == vars["base"] {
+		return expandTemplate(templates.Repo, vars)
+	}
+	vars["branch"] = branch
+	return expandTemplate(templates.Compare, vars)
+}
+
+// forge returns the URL templates and template values for the repository's
+// origin remote. vars is nil when the remote is not on a known forge.
+func (l *linker) forge(repo *git.Repository) (config.ForgeTemplates, map[string]string) {
+	if l == nil || repo == nil {
+		return config.ForgeTemplates{}, nil
+	}
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		return config.ForgeTemplates{}, nil
+	}
+
+	name, ok := l.cfg.Hosts[remote.Host]
+	if !ok {
+		for _, known := range []string{"github", "gitlab", "gitea"} {
+			if strings.Contains(remote.Host, known) {
+				name = known
+				break
+			}
+		}
+	}
+	templates, ok := l.cfg.Forges[name]
+	if !ok {
+		return config.ForgeTemplates{}, nil
+	}
+
+	base := repo.DefaultBranch("origin")
+	if base == "" {
+		base = "main"
+	}
+	return templates, map[string]string{
+		"host":  remote.Host,
+		"owner": remote.Owner,
+		"repo":  remote.Repo,
+		"base":  base,
+	}
+}
+
+// fileURL returns a file:// URL for an absolute path.
+func fileURL(path string) string {
+	if path == "" || !filepath.IsAbs(path) {
+		return ""
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// expandTemplate replaces {name} placeholders with path-escaped values.
+// Branch names keep their slashes, which forges expect unescaped.
+func expandTemplate(template string, vars map[string]string) string {
+	if template == "" || vars == nil {
+		return ""
+	}
+	var pairs []string
+	for name, value := range vars {
+		escaped := url.PathEscape(value)
+		escaped = strings.ReplaceAll(escaped, "%2F", "/")
+		pairs = append(pairs, "{"+name+"}", escaped)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
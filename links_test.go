@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestExpandTemplate(t *testing.T) {
+	const (
+		github = "https://{host}/{owner}/{repo}/compare/{base}...{branch}"
+		gitlab = "https://{host}/{owner}/{repo}/-/compare/{base}...{branch}"
+	)
+	tests := []struct {
+		name     string
+		template string
+		vars     map[string]string
+		want     string
+	}{
+		{
+			name:     "branch with slashes",
+			template: github,
+			vars:     map[string]string{"host": "github.com", "owner": "owner", "repo": "repo", "base": "main", "branch": "feature/JIRA-1234-login"},
+			want:     "https://github.com/owner/repo/compare/main...feature/JIRA-1234-login",
+		},
+		{
+			name:     "nested groups",
+			template: gitlab,
+			vars:     map[string]string{"host": "gitlab.com", "owner": "group/subgroup", "repo": "project", "base": "develop", "branch": "fix"},
+			want:     "https://gitlab.com/group/subgroup/project/-/compare/develop...fix",
+		},
+		{
+			name:     "host with a port",
+			template: "https://{host}/{owner}/{repo}",
+			vars:     map[string]string{"host": "git.example.com:8443", "owner": "team", "repo": "app"},
+			want:     "https://git.example.com:8443/team/app",
+		},
+		{
+			name:     "characters escaped",
+			template: github,
+			vars:     map[string]string{"host": "github.com", "owner": "owner", "repo": "repo", "base": "main", "branch": "fix/issue #12?"},
+			want:     "https://github.com/owner/repo/compare/main...fix/issue%20%2312%3F",
+		},
+		{
+			name:     "unknown placeholder kept",
+			template: "https://{host}/{unknown}",
+			vars:     map[string]string{"host": "github.com"},
+			want:     "https://github.com/{unknown}",
+		},
+		{
+			name:     "no template",
+			template: "",
+			vars:     map[string]string{"host": "github.com"},
+			want:     "",
+		},
+		{
+			name:     "no forge",
+			template: github,
+			want:     "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := expandTemplate(test.template, test.vars); got != test.want {
+				t.Errorf("expandTemplate(%q) = %q, want %q", test.template, got, test.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -10,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/CS-5/cstatus/claude"
+	"github.com/CS-5/cstatus/config"
 	"github.com/CS-5/cstatus/util"
 )
 
@@ -27,29 +31,76 @@ func main() {
 		return
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err := handleReplay(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		if err := handleReport(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Check if there's piped input; if not, show usage
 	if stat, _ := os.Stdin.Stat(); (stat.Mode() & os.ModeCharDevice) == os.ModeCharDevice {
-		fmt.Fprintf(os.Stderr, "Error: No input received. Either pipe JSON input or use 'install' command.\n")
+		fmt.Fprintf(os.Stderr, "Error: No input received. Either pipe JSON input or use the 'install', 'replay' or 'report' command.\n")
 		fmt.Fprintf(os.Stderr, "\nUsage:\n")
 		fmt.Fprintf(os.Stderr, "  echo '{\"model\":{\"display_name\":\"Claude\"}}' | %s\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s install\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s replay [-config file] [-session id] [history.jsonl]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s report\n", os.Args[0])
 		os.Exit(1)
 	}
 
-	claudeContext, err := claude.NewContextFromReader(os.Stdin)
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+		os.Exit(1)
+	}
+
+	claudeContext, err := claude.NewContextFromReader(bytes.NewReader(input))
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating Claude context: %v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Println(util.NewStatusLineBuilder(claudeContext).
-		Append(projectWidget).
-		Append(gitStatusWidget).
-		Append(sessionWidget).
-		Append(contextWidget).
-		Append(blockTimerWidget).
-		Render(),
-	)
+	// A broken config file should not take the statusline down with it
+	cfg, err := config.Load(claudeContext.Code.Workspace.ProjectDir)
+	if err != nil {
+		log.Printf("Warning: using the default config: %v", err)
+		cfg = config.Default()
+	}
+
+	output := renderStatusline(claudeContext, cfg)
+	fmt.Println(output)
+
+	if cfg.History.Enabled {
+		if err := recordHistory(cfg.History.Path, input, output); err != nil {
+			log.Printf("Warning: failed to record history: %v", err)
+		}
+	}
+}
+
+// renderStatusline builds the statusline from the widgets enabled in cfg.
+func renderStatusline(claudeContext *claude.Context, cfg *config.Config) string {
+	widgets := widgetsFor(cfg)
+
+	builder := util.NewStatusLineBuilder(claudeContext)
+	for _, name := range cfg.Widgets {
+		widget, ok := widgets[name]
+		if !ok {
+			log.Printf("Warning: unknown widget %q", name)
+			continue
+		}
+		builder.Append(widget)
+	}
+	return builder.Render()
 }
 
 func handleInstall() error {
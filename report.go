@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/CS-5/cstatus/claude"
+	"github.com/CS-5/cstatus/config"
+	"github.com/CS-5/cstatus/util"
+)
+
+// handleReport prints account-wide usage for the current block and week.
+func handleReport() error {
+	projectDir, _ := os.Getwd()
+	cfg, err := config.Load(projectDir)
+	if err != nil {
+		return err
+	}
+
+	usage := claude.LoadUsageHistory()
+	now := time.Now()
+
+	fmt.Println("Current block")
+	if block := usage.CurrentBlock(now); block != nil {
+		fmt.Printf("  Window      %s – %s (%s left)\n",
+			block.StartTime.Local().Format("15:04"),
+			block.EndTime.Local().Format("15:04"),
+			util.FormatDuration(block.Remaining(now)),
+		)
+		printUsage(block.Usage, block.CostUSD)
+		fmt.Printf("  Burn rate   %s tok/min, %s/min\n",
+			util.FormatCount(block.BurnRate.TokensPerMinute),
+			util.FormatCost(block.BurnRate.CostPerMinute),
+		)
+		fmt.Printf("  Projected   %s tok, %s\n",
+			util.FormatCount(float64(block.ProjectedTokens)),
+			util.FormatCost(block.ProjectedCostUSD),
+		)
+	} else {
+		fmt.Println("  No active block")
+	}
+
+	weekly := usage.Weekly(now, weeklyReset(cfg.Weekly))
+	fmt.Println()
+	fmt.Println("Weekly")
+	fmt.Printf("  Window      %s – %s (resets in %s)\n",
+		weekly.StartTime.Format("Mon Jan 2 15:04"),
+		weekly.EndTime.Format("Mon Jan 2 15:04"),
+		util.FormatDuration(weekly.EndTime.Sub(now)),
+	)
+	printUsage(weekly.Usage, weekly.CostUSD)
+	fmt.Printf("  Active      %d hours\n", weekly.ActiveHours)
+
+	return nil
+}
+
+func printUsage(usage claude.UsageTotals, cost float64) {
+	fmt.Printf("  Tokens      %s (input %s, output %s, cache write %s, cache read %s)\n",
+		util.FormatCount(float64(usage.TotalTokens())),
+		util.FormatCount(float64(usage.InputTokens)),
+		util.FormatCount(float64(usage.OutputTokens)),
+		util.FormatCount(float64(usage.CacheCreationTokens)),
+		util.FormatCount(float64(usage.CacheReadTokens)),
+	)
+	fmt.Printf("  Cost        %s\n", util.FormatCost(cost))
+	fmt.Printf("  Messages    %d\n", usage.Messages)
+}
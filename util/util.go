@@ -2,8 +2,10 @@ package util
 
 import (
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/CS-5/cstatus/claude"
 )
@@ -54,6 +56,10 @@ const (
 	asciiSeparatorRight = "\uE0B0"
 	asciiSeparatorLeft  = "\uE0B2"
 	asciiColorReset     = "\x1b[0m"
+
+	// OSC 8 hyperlinks: https://gist.github.com/egmontkob/eb114294efbcd5adb1944c9f3cb5feda
+	hyperlinkStart = "\x1b]8;;"
+	hyperlinkEnd   = "\x1b\\"
 )
 
 type Segment struct {
@@ -61,6 +67,10 @@ type Segment struct {
 	text  string
 	bgHex string
 	fgHex string
+	link  string
+
+	// subs are rendered directly after the segment, each with its own colors
+	subs []*Segment
 }
 
 func (s *Segment) IsEmpty() bool {
@@ -76,8 +86,44 @@ func NewSegment(icon, text, fgColor, bgColor string) *Segment {
 	}
 }
 
+// Append adds a sub-segment that is rendered after s with its own colors, as
+// part of the same widget.
+func (s *Segment) Append(sub *Segment) *Segment {
+	if !sub.IsEmpty() {
+		s.subs = append(s.subs, sub)
+	}
+	return s
+}
+
+// WithLink makes the segment's text a hyperlink to url. Callers should check
+// HyperlinksSupported first, as some terminals print the escape codes.
+func (s *Segment) WithLink(url string) *Segment {
+	s.link = url
+	return s
+}
+
 func (s *Segment) String() string {
-	return fmt.Sprintf("%s%s%s %s %s", s.BgColor(), s.FgColor(), s.icon, s.text, asciiColorReset)
+	content := fmt.Sprintf("%s %s", s.icon, s.text)
+	if s.link != "" {
+		content = hyperlinkStart + s.link + hyperlinkEnd + content + hyperlinkStart + hyperlinkEnd
+	}
+	result := fmt.Sprintf("%s%s%s %s", s.BgColor(), s.FgColor(), content, asciiColorReset)
+	prev := s
+	for _, sub := range s.subs {
+		result += sub.BgColor() + hexToAnsi(prev.bgHex, false) + asciiSeparatorRight + asciiColorReset
+		result += sub.String()
+		prev = sub.last()
+	}
+	return result
+}
+
+// last returns the segment rendered last, whose background the separator
+// after s continues from.
+func (s *Segment) last() *Segment {
+	if len(s.subs) == 0 {
+		return s
+	}
+	return s.subs[len(s.subs)-1].last()
 }
 
 func (s *Segment) BgColor() string {
@@ -93,7 +139,33 @@ func (s *Segment) Sep(next *Segment) string {
 	if next != nil {
 		sep = next.BgColor()
 	}
-	return sep + hexToAnsi(s.bgHex, false) + asciiSeparatorRight + asciiColorReset
+	return sep + hexToAnsi(s.last().bgHex, false) + asciiSeparatorRight + asciiColorReset
+}
+
+// HyperlinksSupported reports whether the terminal is known to render OSC 8
+// hyperlinks. CSTATUS_HYPERLINKS set to "1" or "0" overrides detection.
+func HyperlinksSupported() bool {
+	switch os.Getenv("CSTATUS_HYPERLINKS") {
+	case "1":
+		return true
+	case "0":
+		return false
+	}
+
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper", "Tabby", "rio":
+		return true
+	}
+	for _, name := range []string{"KITTY_WINDOW_ID", "WT_SESSION", "KONSOLE_VERSION", "ALACRITTY_WINDOW_ID"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	// VTE based terminals such as GNOME Terminal support them since 0.50
+	if vte, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && vte >= 5000 {
+		return true
+	}
+	return strings.Contains(os.Getenv("TERM"), "kitty")
 }
 
 func hexToAnsi(hex string, background bool) string {
@@ -132,3 +204,45 @@ func FormatTokens(cost float64) string {
 	}
 	return fmt.Sprintf("%d", tokens)
 }
+
+// FormatCount formats a count with a K or M suffix, e.g. "12.3K".
+func FormatCount(n float64) string {
+	if n >= 1000000 {
+		return fmt.Sprintf("%.1fM", n/1000000)
+	}
+	if n >= 1000 {
+		return fmt.Sprintf("%.1fK", n/1000)
+	}
+	return fmt.Sprintf("%.0f", n)
+}
+
+// FormatDuration formats a duration in hours and minutes, e.g. "2hr 5m", or
+// days and hours once it exceeds a day, e.g. "3d 4hr".
+func FormatDuration(d time.Duration) string {
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+
+	if hours >= 24 {
+		return fmt.Sprintf("%dd %dhr", hours/24, hours%24)
+	}
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	} else if minutes == 0 {
+		return fmt.Sprintf("%dhr", hours)
+	}
+	return fmt.Sprintf("%dhr %dm", hours, minutes)
+}
+
+// defaultProgressWidth is used when a progress bar is given no usable width.
+const defaultProgressWidth = 10
+
+// ProgressBar renders fraction (0 to 1) as a bar of width cells. A width of
+// zero or less renders a bar of the default width.
+func ProgressBar(fraction float64, width int) string {
+	if width <= 0 {
+		width = defaultProgressWidth
+	}
+	filled := int(fraction*float64(width) + 0.5)
+	filled = min(max(filled, 0), width)
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
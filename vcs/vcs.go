@@ -0,0 +1,54 @@
+package vcs
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// commandTimeout bounds every version control command so a slow repository
+// cannot stall the statusline.
+const commandTimeout = 2 * time.Second
+
+// Tool is a version control command line tool.
+type Tool struct {
+	// Name is the binary, looked up in PATH
+	Name string
+	// Env is added to the environment of every invocation
+	Env []string
+}
+
+// Run executes the tool in dir and returns its standard output. It fails
+// with exec.ErrNotFound when the tool is not installed.
+func (t Tool) Run(dir string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, t.Name, args...)
+	cmd.Dir = dir
+	if len(t.Env) > 0 {
+		cmd.Env = append(os.Environ(), t.Env...)
+	}
+	return cmd.Output()
+}
+
+// FindRoot returns the nearest parent of dir, dir included, that contains a
+// directory named marker, or notFound if there is none.
+func FindRoot(dir, marker string, notFound error) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for current := dir; ; {
+		if info, err := os.Stat(filepath.Join(current, marker)); err == nil && info.IsDir() {
+			return current, nil
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", notFound
+		}
+		current = parent
+	}
+}
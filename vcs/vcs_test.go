@@ -0,0 +1,61 @@
+package vcs
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindRoot(t *testing.T) {
+	errNotFound := errors.New("not found")
+	dir := t.TempDir()
+	root := filepath.Join(dir, "repo")
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(filepath.Join(root, ".jj"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// A marker that is a file does not count
+	if err := os.WriteFile(filepath.Join(nested, ".jj"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, start := range []string{root, nested} {
+		if got, err := FindRoot(start, ".jj", errNotFound); err != nil || got != root {
+			t.Errorf("FindRoot(%s) = %q, %v, want %q", start, got, err, root)
+		}
+	}
+	if _, err := FindRoot(dir, ".jj", errNotFound); err != errNotFound {
+		t.Errorf("FindRoot outside a repository = %v, want %v", err, errNotFound)
+	}
+}
+
+func TestRun(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	dir := t.TempDir()
+	tool := Tool{Name: "sh", Env: []string{"VCS_TEST=set"}}
+
+	output, err := tool.Run(dir, "-c", `pwd; echo "$VCS_TEST"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 2 || lines[1] != "set" {
+		t.Errorf("output = %q, want the directory and the added variable", output)
+	}
+	if resolved, _ := filepath.EvalSymlinks(dir); lines[0] != dir && lines[0] != resolved {
+		t.Errorf("ran in %s, want %s", lines[0], dir)
+	}
+
+	missing := Tool{Name: "cstatus-no-such-tool"}
+	if _, err := missing.Run(dir); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("missing tool error = %v, want exec.ErrNotFound", err)
+	}
+}
@@ -1,117 +1,370 @@
 package main
 
 import (
-	"context"
 	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
+	"log"
 	"strings"
 	"time"
 
 	"github.com/CS-5/cstatus/claude"
+	"github.com/CS-5/cstatus/config"
+	"github.com/CS-5/cstatus/git"
 	"github.com/CS-5/cstatus/util"
 )
 
-func projectWidget(claudeContext *claude.Context) *util.Segment {
-	if claudeContext.ProjectName == "" {
-		return nil
+type widgetFunc func(claudeContext *claude.Context) *util.Segment
+
+// widgetsFor returns the available widgets keyed by the name used in the config.
+func widgetsFor(cfg *config.Config) map[string]widgetFunc {
+	links := newLinker(cfg.Links)
+
+	return map[string]widgetFunc{
+		"project":   newProjectWidget(links),
+		"directory": newDirectoryWidget(cfg.Directory),
+		"git":       newGitStatusWidget(cfg.Git, links),
+		"vcs":       newVCSWidget(cfg, links),
+		"freshness": newFreshnessWidget(cfg.Freshness),
+		"diff":      newDiffWidget(cfg.Diff),
+		"model":     newModelWidget(cfg.Model),
+		"session":   newSessionWidget(links),
+		"context":   contextWidget,
+		"version":   versionWidget,
+		"block":     newBlockTimerWidget(cfg.Block),
+		"burn":      newBurnRateWidget(cfg.Burn),
+		"limit":     newLimitWidget(cfg.Limit),
+		"weekly":    newWeeklyWidget(cfg.Weekly),
+		"cache":     cacheWidget,
+		"agents":    subagentWidget,
+
+		"lines":      newLinesWidget(cfg.Lines),
+		"duration":   newDurationWidget(cfg.Duration),
+		"api_share":  newAPIShareWidget(cfg.APIShare),
+		"efficiency": newEfficiencyWidget(cfg.Efficiency),
 	}
-	return util.NewSegment("", claudeContext.ProjectName, "#ffffff", "#8b4513")
 }
 
-func gitStatusWidget(claudeContext *claude.Context) *util.Segment {
-	if claudeContext == nil || claudeContext.WorkingDir == "" {
-		return nil
+func newProjectWidget(links *linker) widgetFunc {
+	return func(claudeContext *claude.Context) *util.Segment {
+		if claudeContext.ProjectName == "" {
+			return nil
+		}
+		segment := util.NewSegment("", claudeContext.ProjectName, "#ffffff", "#8b4513")
+		if repo, err := git.Discover(claudeContext.Code.Workspace.ProjectDir); err == nil {
+			links.link(segment, links.repoURL(repo))
+		}
+		return segment
 	}
+}
+
+func newModelWidget(cfg config.ModelConfig) widgetFunc {
+	return func(claudeContext *claude.Context) *util.Segment {
+		if cfg.Split && claudeContext.TokenMetrics != nil {
+			var parts []string
+			for _, model := range claudeContext.TokenMetrics.Models.Breakdown() {
+				parts = append(parts, fmt.Sprintf("%s %s", model.Model, util.FormatCost(model.CostUSD)))
+			}
+			if len(parts) > 0 {
+				return util.NewSegment("⚡", strings.Join(parts, " · "), "#ffffff", "#2d2d2d")
+			}
+		}
+
+		if claudeContext.Code.Model.DisplayName == "" {
+			return nil
+		}
+		return util.NewSegment("⚡", claudeContext.Code.Model.DisplayName, "#ffffff", "#2d2d2d")
+	}
+}
+
+func newSessionWidget(links *linker) widgetFunc {
+	return func(claudeContext *claude.Context) *util.Segment {
+		if claudeContext == nil || claudeContext.Code == nil {
+			return nil
+		}
 
-	gitDir := filepath.Join(claudeContext.WorkingDir, ".git")
-	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
+		cost := claudeContext.Code.Cost.TotalCostUSD
+		costStr := util.FormatCost(cost)
+		tokensStr := util.FormatTokens(cost)
+
+		segment := util.NewSegment("§", fmt.Sprintf("%s (%s)", costStr, tokensStr), "#00ffff", "#202020")
+		return links.link(segment, fileURL(claudeContext.Code.TranscriptPath))
+	}
+}
+
+func contextWidget(claudeContext *claude.Context) *util.Segment {
+	if claudeContext == nil || claudeContext.TokenMetrics == nil || claudeContext.TokenMetrics.ContextLength == 0 {
 		return nil
 	}
 
-	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-	cmd.Dir = claudeContext.WorkingDir
+	ctxStr := util.FormatTokens(float64(claudeContext.TokenMetrics.ContextLength))
+
+	// All current Claude models have 200k context window according to reference implementation
+	contextWindow := int64(200000)
+	percentage := float64(claudeContext.TokenMetrics.ContextLength) / float64(contextWindow) * 100
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-	cmd = exec.CommandContext(ctx, "git", "rev-parse", "--abbrev-ref", "HEAD")
-	cmd.Dir = claudeContext.WorkingDir
+	return util.NewSegment("🧠", fmt.Sprintf("%s (%.1f%%)", ctxStr, percentage), "#ff00ff", "#202020")
+}
 
-	output, err := cmd.Output()
-	if err != nil {
+func cacheWidget(claudeContext *claude.Context) *util.Segment {
+	if claudeContext == nil || claudeContext.TokenMetrics == nil {
 		return nil
 	}
 
-	branchName := strings.TrimSpace(string(output))
-	if branchName == "" {
+	metrics := claudeContext.TokenMetrics
+	if metrics.CacheReadTokens == 0 && metrics.CacheCreationTokens == 0 {
 		return nil
 	}
 
-	return util.NewSegment("⎇", branchName, "#ffffff", "#ff6b6b")
+	savings := metrics.CacheSavingsUSD()
+	text := fmt.Sprintf("%.0f%% hit · saved %s", metrics.CacheHitRatio()*100, util.FormatCost(savings))
+	return util.NewSegment("♻️", text, "#98fb98", "#202020")
 }
 
-func modelWidget(claudeContext *claude.Context) *util.Segment {
-	if claudeContext.Code.Model.DisplayName == "" {
+func subagentWidget(claudeContext *claude.Context) *util.Segment {
+	if claudeContext == nil || claudeContext.SubagentMetrics == nil || claudeContext.SubagentMetrics.Invocations() == 0 {
 		return nil
 	}
-	return util.NewSegment("⚡", claudeContext.Code.Model.DisplayName, "#ffffff", "#2d2d2d")
+
+	subagents := claudeContext.SubagentMetrics
+	usage := subagents.Usage.Totals()
+	text := fmt.Sprintf("%d agents · %s tok · %s",
+		subagents.Invocations(),
+		util.FormatCount(float64(usage.TotalTokens())),
+		util.FormatCost(subagents.Usage.CostUSD()),
+	)
+	if running := subagents.Running(claudeContext.Now); running > 0 {
+		return util.NewSegment("🤖", fmt.Sprintf("%d running · %s", running, text), "#000000", "#87ceeb")
+	}
+	return util.NewSegment("🤖", text, "#87ceeb", "#202020")
 }
 
-func sessionWidget(claudeContext *claude.Context) *util.Segment {
-	if claudeContext == nil || claudeContext.Code == nil {
+func versionWidget(claudeContext *claude.Context) *util.Segment {
+	if claudeContext.Code == nil || claudeContext.Code.Version == "" {
 		return nil
 	}
+	return util.NewSegment("🔧", fmt.Sprintf("v%s", claudeContext.Code.Version), "#ffffff", "#666666")
+}
 
-	cost := claudeContext.Code.Cost.TotalCostUSD
-	costStr := util.FormatCost(cost)
-	tokensStr := util.FormatTokens(cost)
-
-	return util.NewSegment("§", fmt.Sprintf("%s (%s)", costStr, tokensStr), "#00ffff", "#202020")
+// loadLocation returns the named time zone, or local time if name is empty
+// or unknown.
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Warning: unknown time zone %q: %v", name, err)
+		return time.Local
+	}
+	return location
 }
 
+func newBlockTimerWidget(cfg config.BlockConfig) widgetFunc {
+	location := loadLocation(cfg.TimeZone)
 
-func contextWidget(claudeContext *claude.Context) *util.Segment {
-	if claudeContext == nil || claudeContext.TokenMetrics == nil || claudeContext.TokenMetrics.ContextLength == 0 {
-		return nil
+	clockFormat := "15:04"
+	if cfg.Clock == "12h" {
+		clockFormat = "3:04pm"
 	}
 
-	ctxStr := util.FormatTokens(float64(claudeContext.TokenMetrics.ContextLength))
+	return func(claudeContext *claude.Context) *util.Segment {
+		// Return nil when no active block - similar to reference implementation
+		if claudeContext == nil || claudeContext.BlockMetrics == nil || claudeContext.BlockMetrics.StartTime.IsZero() {
+			return nil
+		}
 
-	// All current Claude models have 200k context window according to reference implementation
-	contextWindow := int64(200000)
-	percentage := float64(claudeContext.TokenMetrics.ContextLength) / float64(contextWindow) * 100
+		block := claudeContext.BlockMetrics
+		now := claudeContext.Now
 
-	return util.NewSegment("🧠", fmt.Sprintf("%s (%.1f%%)", ctxStr, percentage), "#ff00ff", "#202020")
+		var parts []string
+		for _, view := range cfg.Show {
+			switch view {
+			case "elapsed":
+				parts = append(parts, util.FormatDuration(block.Elapsed(now)))
+			case "remaining":
+				parts = append(parts, util.FormatDuration(block.Remaining(now))+" left")
+			case "reset":
+				parts = append(parts, "resets "+block.EndTime.In(location).Format(clockFormat))
+			case "progress":
+				parts = append(parts, util.ProgressBar(block.Progress(now), cfg.ProgressWidth))
+			case "usage":
+				parts = append(parts, fmt.Sprintf("%s tok %s", util.FormatCount(float64(block.Usage.TotalTokens())), util.FormatCost(block.CostUSD)))
+			default:
+				log.Printf("Warning: unknown block view %q", view)
+			}
+		}
+		if len(parts) == 0 {
+			return nil
+		}
+
+		text := strings.Join(parts, " · ")
+		if cfg.WarnMinutes > 0 && block.Remaining(now) < time.Duration(cfg.WarnMinutes)*time.Minute {
+			return util.NewSegment("⚠️", text, "#ffffff", "#cc3333")
+		}
+		return util.NewSegment("⏱️", text, "#ffff00", "#333333")
+	}
 }
 
-func versionWidget(claudeContext *claude.Context) *util.Segment {
-	if claudeContext.Code == nil || claudeContext.Code.Version == "" {
-		return nil
+func newBurnRateWidget(cfg config.BurnConfig) widgetFunc {
+	return func(claudeContext *claude.Context) *util.Segment {
+		if claudeContext == nil || claudeContext.BlockMetrics == nil {
+			return nil
+		}
+
+		block := claudeContext.BlockMetrics
+		var parts []string
+		for _, view := range cfg.Show {
+			switch view {
+			case "tokens":
+				parts = append(parts, util.FormatCount(block.BurnRate.TokensPerMinute)+"/min")
+			case "cost":
+				parts = append(parts, util.FormatCost(block.BurnRate.CostPerMinute)+"/min")
+			case "projection":
+				parts = append(parts, fmt.Sprintf("→ %s %s", util.FormatCost(block.ProjectedCostUSD), util.FormatCount(float64(block.ProjectedTokens))))
+			default:
+				log.Printf("Warning: unknown burn view %q", view)
+			}
+		}
+		if len(parts) == 0 {
+			return nil
+		}
+
+		return util.NewSegment("🔥", strings.Join(parts, " · "), "#ffa500", "#2d2d2d")
 	}
-	return util.NewSegment("🔧", fmt.Sprintf("v%s", claudeContext.Code.Version), "#ffffff", "#666666")
 }
 
-func blockTimerWidget(claudeContext *claude.Context) *util.Segment {
-	// Return nil when no active block - similar to reference implementation
-	if claudeContext == nil || claudeContext.BlockMetrics == nil || claudeContext.BlockMetrics.StartTime.IsZero() {
-		return nil
+func newLimitWidget(cfg config.LimitConfig) widgetFunc {
+	return func(claudeContext *claude.Context) *util.Segment {
+		if claudeContext == nil || claudeContext.BlockMetrics == nil {
+			return nil
+		}
+
+		block := claudeContext.BlockMetrics
+		now := claudeContext.Now
+
+		limit := cfg.Tokens
+		if limit == 0 {
+			limit = claude.PlanTokenLimits[cfg.Plan]
+		}
+		if cfg.Mode == "learned" && claudeContext.Usage != nil {
+			if learned := claudeContext.Usage.MaxBlockLimitTokens(now); learned > 0 {
+				limit = learned
+			}
+		}
+		if limit <= 0 {
+			return nil
+		}
+
+		used := block.Usage.LimitTokens()
+		percentage := float64(used) / float64(limit) * 100
+		text := fmt.Sprintf("%.0f%%", percentage)
+
+		// Predict when the limit is hit if it happens before the block resets
+		if rate := block.BurnRate.LimitTokensPerMinute; rate > 0 && used < limit {
+			toLimit := time.Duration(float64(limit-used) / rate * float64(time.Minute))
+			if toLimit < block.Remaining(now) {
+				text += " · limit in " + util.FormatDuration(toLimit)
+			}
+		}
+
+		fg, bg := cfg.Thresholds.Colors(percentage, "#ffffff", "#2d5016")
+		return util.NewSegment("📊", text, fg, bg)
 	}
+}
 
-	elapsed := time.Since(claudeContext.BlockMetrics.StartTime)
-	hours := int(elapsed.Hours())
-	minutes := int(elapsed.Minutes()) % 60
+// weeklyReset converts the weekly config, falling back to Monday midnight
+// for values that do not parse.
+func weeklyReset(cfg config.WeeklyConfig) claude.WeeklyReset {
+	reset := claude.WeeklyReset{
+		Weekday:  time.Monday,
+		Location: loadLocation(cfg.TimeZone),
+	}
 
-	var timeStr string
-	if hours == 0 && minutes == 0 {
-		timeStr = "0m"
-	} else if hours == 0 {
-		timeStr = fmt.Sprintf("%dm", minutes)
-	} else if minutes == 0 {
-		timeStr = fmt.Sprintf("%dhr", hours)
+	weekdays := map[string]time.Weekday{}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		weekdays[strings.ToLower(day.String())] = day
+	}
+	if day, ok := weekdays[strings.ToLower(cfg.ResetDay)]; ok {
+		reset.Weekday = day
 	} else {
-		timeStr = fmt.Sprintf("%dhr %dm", hours, minutes)
+		log.Printf("Warning: unknown weekly reset day %q", cfg.ResetDay)
 	}
 
-	return util.NewSegment("⏱️", timeStr, "#ffff00", "#333333")
+	if t, err := time.Parse("15:04", cfg.ResetTime); err == nil {
+		reset.Hour, reset.Minute = t.Hour(), t.Minute()
+	} else {
+		log.Printf("Warning: invalid weekly reset time %q", cfg.ResetTime)
+	}
+	return reset
+}
+
+func newWeeklyWidget(cfg config.WeeklyConfig) widgetFunc {
+	reset := weeklyReset(cfg)
+
+	return func(claudeContext *claude.Context) *util.Segment {
+		if claudeContext == nil || claudeContext.Usage == nil {
+			return nil
+		}
+
+		now := claudeContext.Now
+		weekly := claudeContext.Usage.Weekly(now, reset)
+		text := fmt.Sprintf("%s tok %s · resets in %s",
+			util.FormatCount(float64(weekly.Usage.TotalTokens())),
+			util.FormatCost(weekly.CostUSD),
+			util.FormatDuration(weekly.EndTime.Sub(now)),
+		)
+		return util.NewSegment("📅", text, "#ffffff", "#4b0082")
+	}
+}
+
+func newLinesWidget(cfg config.ThresholdConfig) widgetFunc {
+	return func(claudeContext *claude.Context) *util.Segment {
+		cost := claudeContext.Code.Cost
+		changed := cost.TotalLinesAdded + cost.TotalLinesRemoved
+		if changed == 0 {
+			return nil
+		}
+
+		fg, bg := cfg.Thresholds.Colors(float64(changed), "#ffffff", "#2f4f4f")
+		return util.NewSegment("±", fmt.Sprintf("+%d −%d", cost.TotalLinesAdded, cost.TotalLinesRemoved), fg, bg)
+	}
+}
+
+func newDurationWidget(cfg config.ThresholdConfig) widgetFunc {
+	return func(claudeContext *claude.Context) *util.Segment {
+		duration := time.Duration(claudeContext.Code.Cost.TotalDurationMs) * time.Millisecond
+		if duration <= 0 {
+			return nil
+		}
+
+		fg, bg := cfg.Thresholds.Colors(duration.Minutes(), "#ffffff", "#3a3a3a")
+		return util.NewSegment("⌛", util.FormatDuration(duration), fg, bg)
+	}
+}
+
+func newAPIShareWidget(cfg config.ThresholdConfig) widgetFunc {
+	return func(claudeContext *claude.Context) *util.Segment {
+		cost := claudeContext.Code.Cost
+		if cost.TotalDurationMs <= 0 {
+			return nil
+		}
+
+		// Share of wall-clock time spent waiting on the API
+		share := float64(cost.TotalAPIDurationMs) / float64(cost.TotalDurationMs) * 100
+		fg, bg := cfg.Thresholds.Colors(share, "#ffffff", "#3a3a3a")
+		return util.NewSegment("☁", fmt.Sprintf("API %.0f%%", share), fg, bg)
+	}
+}
+
+func newEfficiencyWidget(cfg config.ThresholdConfig) widgetFunc {
+	return func(claudeContext *claude.Context) *util.Segment {
+		cost := claudeContext.Code.Cost
+		changed := cost.TotalLinesAdded + cost.TotalLinesRemoved
+		if changed == 0 || cost.TotalCostUSD == 0 {
+			return nil
+		}
+
+		per100 := cost.TotalCostUSD / float64(changed) * 100
+		fg, bg := cfg.Thresholds.Colors(per100, "#ffffff", "#2f4f4f")
+		return util.NewSegment("⚙", util.FormatCost(per100)+"/100 lines", fg, bg)
+	}
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/CS-5/cstatus/claude"
+	"github.com/CS-5/cstatus/config"
+	"github.com/CS-5/cstatus/util"
+)
+
+// newDirectoryWidget shows where in the project Claude is working, e.g.
+// "cstatus/s/billing/i/api": the path is relative to the project, an alias
+// or the home directory, and intermediate directories are abbreviated the
+// way the fish shell does, except for anchors such as module roots.
+func newDirectoryWidget(cfg config.DirectoryConfig) widgetFunc {
+	return func(claudeContext *claude.Context) *util.Segment {
+		if claudeContext == nil || claudeContext.WorkingDir == "" {
+			return nil
+		}
+
+		root, label, rel := directoryRoot(cfg, claudeContext)
+		var components []string
+		if rel != "." {
+			components = strings.Split(rel, string(filepath.Separator))
+		}
+
+		// Abbreviate every directory but the last, checking each one for anchors
+		anchor := -1
+		current := root
+		for i, component := range components {
+			current = filepath.Join(current, component)
+			if isAnchor(current, cfg.Anchors) {
+				anchor = i
+				continue
+			}
+			if i < len(components)-1 {
+				components[i] = abbreviate(component, cfg.AbbreviateLength)
+			}
+		}
+
+		// Collapse the start of long paths, keeping the innermost anchor
+		if cfg.MaxDepth > 0 && len(components) > cfg.MaxDepth {
+			cut := len(components) - cfg.MaxDepth
+			var kept []string
+			if anchor >= 0 && anchor < cut {
+				if anchor > 0 {
+					kept = append(kept, "…")
+				}
+				kept = append(kept, components[anchor])
+				if anchor < cut-1 {
+					kept = append(kept, "…")
+				}
+			} else {
+				kept = append(kept, "…")
+			}
+			components = append(kept, components[cut:]...)
+		}
+
+		text := label
+		if len(components) > 0 {
+			text = strings.TrimSuffix(label, "/") + "/" + strings.Join(components, "/")
+		}
+		return util.NewSegment("📁", text, "#ffffff", "#4a4a8a")
+	}
+}
+
+// directoryRoot picks what the working directory is shown relative to: the
+// longest matching alias, then the project, then home, then the filesystem
+// root. It returns the root, the label shown for it and the relative path.
+func directoryRoot(cfg config.DirectoryConfig, claudeContext *claude.Context) (string, string, string) {
+	dir := filepath.Clean(claudeContext.WorkingDir)
+
+	var bestRoot, bestLabel string
+	for path, alias := range cfg.Aliases {
+		path = filepath.Clean(config.ExpandHome(path))
+		if within(dir, path) && len(path) > len(bestRoot) {
+			bestRoot, bestLabel = path, alias
+		}
+	}
+	if bestRoot != "" {
+		rel, _ := filepath.Rel(bestRoot, dir)
+		return bestRoot, bestLabel, rel
+	}
+
+	if projectDir := claudeContext.Code.Workspace.ProjectDir; projectDir != "" && within(dir, projectDir) {
+		rel, _ := filepath.Rel(projectDir, dir)
+		return projectDir, filepath.Base(projectDir), rel
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil && within(dir, homeDir) {
+		rel, _ := filepath.Rel(homeDir, dir)
+		return homeDir, "~", rel
+	}
+
+	root := filepath.VolumeName(dir) + string(filepath.Separator)
+	rel, _ := filepath.Rel(root, dir)
+	return root, root, rel
+}
+
+// within reports whether dir is root or inside it.
+func within(dir, root string) bool {
+	rel, err := filepath.Rel(root, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isAnchor reports whether dir contains any of the anchor files.
+func isAnchor(dir string, anchors []string) bool {
+	for _, anchor := range anchors {
+		if _, err := os.Stat(filepath.Join(dir, anchor)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// abbreviate shortens a directory name to its first n characters, keeping a
+// leading dot so hidden directories stay recognizable.
+func abbreviate(name string, n int) string {
+	if n <= 0 {
+		return name
+	}
+	prefix := ""
+	if strings.HasPrefix(name, ".") {
+		prefix, name = ".", name[1:]
+	}
+	runes := []rune(name)
+	if len(runes) <= n {
+		return prefix + name
+	}
+	return prefix + string(runes[:n])
+}
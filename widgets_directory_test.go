@@ -0,0 +1,177 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CS-5/cstatus/claude"
+	"github.com/CS-5/cstatus/config"
+	"github.com/CS-5/cstatus/util"
+)
+
+// directoryTree creates home/src/myproject/services/billing/internal/api,
+// with billing as a Go module, and returns the home and project directories.
+func directoryTree(t *testing.T) (string, string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	project := filepath.Join(home, "src", "myproject")
+	if err := os.MkdirAll(filepath.Join(project, "services", "billing", "internal", "api"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "services", "billing", "go.mod"), []byte("module billing\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return home, project
+}
+
+func directoryContext(workingDir, projectDir string) *claude.Context {
+	code := &claude.ClaudeCode{}
+	code.Workspace.ProjectDir = projectDir
+	return &claude.Context{Code: code, WorkingDir: workingDir}
+}
+
+func TestDirectoryWidget(t *testing.T) {
+	home, project := directoryTree(t)
+	api := filepath.Join(project, "services", "billing", "internal", "api")
+	defaults := config.Default().Directory
+
+	tests := []struct {
+		name    string
+		cfg     func(cfg *config.DirectoryConfig)
+		dir     string
+		project string
+		want    string
+	}{
+		{
+			name:    "abbreviated around an anchor",
+			dir:     api,
+			project: project,
+			want:    "myproject/s/billing/i/api",
+		},
+		{
+			name:    "project root",
+			dir:     project,
+			project: project,
+			want:    "myproject",
+		},
+		{
+			name:    "collapsed keeping the anchor",
+			cfg:     func(cfg *config.DirectoryConfig) { cfg.MaxDepth = 2 },
+			dir:     api,
+			project: project,
+			want:    "myproject/…/billing/i/api",
+		},
+		{
+			name:    "collapsed on both sides of the anchor",
+			cfg:     func(cfg *config.DirectoryConfig) { cfg.MaxDepth = 1 },
+			dir:     api,
+			project: project,
+			want:    "myproject/…/billing/…/api",
+		},
+		{
+			name:    "collapsed without anchors",
+			cfg:     func(cfg *config.DirectoryConfig) { cfg.MaxDepth, cfg.Anchors = 2, nil },
+			dir:     api,
+			project: project,
+			want:    "myproject/…/i/api",
+		},
+		{
+			name:    "longer abbreviations",
+			cfg:     func(cfg *config.DirectoryConfig) { cfg.AbbreviateLength = 3 },
+			dir:     api,
+			project: project,
+			want:    "myproject/ser/billing/int/api",
+		},
+		{
+			name:    "no abbreviation",
+			cfg:     func(cfg *config.DirectoryConfig) { cfg.AbbreviateLength, cfg.MaxDepth = 0, 0 },
+			dir:     api,
+			project: project,
+			want:    "myproject/services/billing/internal/api",
+		},
+		{
+			name: "innermost alias",
+			cfg: func(cfg *config.DirectoryConfig) {
+				cfg.Aliases = map[string]string{
+					"~/src":                            "src",
+					filepath.Join(project, "services"): "svc",
+				}
+			},
+			dir:     api,
+			project: project,
+			want:    "svc/billing/i/api",
+		},
+		{
+			name:    "home outside the project",
+			dir:     filepath.Join(project, "services"),
+			project: filepath.Join(home, "elsewhere"),
+			want:    "~/s/m/services",
+		},
+		{
+			name: "home without a project",
+			dir:  home,
+			want: "~",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := defaults
+			if test.cfg != nil {
+				test.cfg(&cfg)
+			}
+			got := newDirectoryWidget(cfg)(directoryContext(test.dir, test.project))
+			want := util.NewSegment("📁", test.want, "#ffffff", "#4a4a8a")
+			if got.String() != want.String() {
+				t.Errorf("directory widget shows %q, want %q", got.String(), want.String())
+			}
+		})
+	}
+}
+
+func TestDirectoryRoot(t *testing.T) {
+	home, project := directoryTree(t)
+	outside := t.TempDir()
+	cfg := config.Default().Directory
+
+	tests := []struct {
+		name                  string
+		dir, project          string
+		root, label, relative string
+	}{
+		{"project", filepath.Join(project, "services"), project, project, "myproject", "services"},
+		{"home", filepath.Join(project, "services"), outside, home, "~", filepath.Join("src", "myproject", "services")},
+		{"sibling of the project", project + "-old", project, home, "~", filepath.Join("src", "myproject-old")},
+		{"filesystem root", outside, "", "/", "/", outside[1:]},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, label, relative := directoryRoot(cfg, directoryContext(test.dir, test.project))
+			if root != test.root || label != test.label || relative != test.relative {
+				t.Errorf("directoryRoot(%q) = %q, %q, %q, want %q, %q, %q",
+					test.dir, root, label, relative, test.root, test.label, test.relative)
+			}
+		})
+	}
+}
+
+func TestAbbreviate(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		want string
+	}{
+		{"services", 1, "s"},
+		{"services", 0, "services"},
+		{".config", 1, ".c"},
+		{"ab", 3, "ab"},
+		{"überall", 2, "üb"},
+	}
+	for _, test := range tests {
+		if got := abbreviate(test.name, test.n); got != test.want {
+			t.Errorf("abbreviate(%q, %d) = %q, want %q", test.name, test.n, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/CS-5/cstatus/claude"
+	"github.com/CS-5/cstatus/config"
+	"github.com/CS-5/cstatus/git"
+	"github.com/CS-5/cstatus/util"
+)
+
+// statusIndicators need `git status`; the rest are read from the git
+// directory directly, so a widget showing only them never runs git.
+var statusIndicators = []string{"ahead_behind", "staged", "unstaged", "untracked", "conflicted", "stash"}
+
+// gitState is what the git widget's indicators render from. Status is nil
+// when no configured indicator needs it or git could not report it.
+type gitState struct {
+	repo      *git.Repository
+	head      *git.Head
+	operation *git.Operation
+	status    *git.GitStatus
+
+	branch *branchRewriter
+	// ticket is set by the branch indicator when it extracts a ticket key
+	ticket string
+}
+
+func newGitStatusWidget(cfg config.GitConfig, links *linker) widgetFunc {
+	needsStatus := slices.ContainsFunc(cfg.Show, func(indicator string) bool {
+		return slices.Contains(statusIndicators, indicator)
+	})
+
+	branch := newBranchRewriter(cfg.Branch)
+
+	return func(claudeContext *claude.Context) *util.Segment {
+		if claudeContext == nil || claudeContext.WorkingDir == "" {
+			return nil
+		}
+
+		repo, err := git.Discover(claudeContext.WorkingDir)
+		if err != nil {
+			return nil
+		}
+		state := &gitState{repo: repo, operation: repo.Operation(), branch: branch}
+
+		head, headErr := repo.Head()
+		if headErr == nil {
+			state.head = head
+		}
+
+		// Bare repositories have no working tree to report on
+		if repo.Bare {
+			if head != nil && head.Branch != "" {
+				return util.NewSegment("⎇", head.Branch+" (bare)", "#ffffff", "#ff6b6b")
+			}
+			return util.NewSegment("⎇", "bare", "#ffffff", "#ff6b6b")
+		}
+
+		// Fall back to git for the branch when HEAD could not be read,
+		// e.g. in repositories using the reftable format. When git fails
+		// (not installed, refusing a repository it considers unsafe, or
+		// too slow) the indicators read from HEAD are still shown.
+		if needsStatus || headErr != nil {
+			status, err := git.ReadStatus(claudeContext.WorkingDir)
+			if err != nil && headErr != nil {
+				return nil
+			}
+			if err == nil {
+				state.status = status
+				if state.head == nil {
+					state.head = &git.Head{Branch: status.Branch, Detached: status.Detached, Commit: status.Commit}
+				}
+			}
+		}
+
+		var parts []string
+		for _, indicator := range cfg.Show {
+			if part := gitIndicator(state, indicator); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) == 0 {
+			return nil
+		}
+
+		fg, bg := "#ffffff", "#ff6b6b"
+		if state.status != nil && state.status.IsDirty() {
+			fg, bg = cfg.DirtyFg, cfg.DirtyBg
+		}
+		segment := vcsSegment("⎇", parts, state.ticket, fg, bg, cfg.Branch)
+		if !state.head.Detached {
+			links.link(segment, links.compareURL(repo, state.head.Branch))
+		}
+		return segment
+	}
+}
+
+// gitIndicator renders one of the git widget's indicators, or "" when there
+// is nothing to show.
+func gitIndicator(state *gitState, indicator string) string {
+	count := func(symbol string, n int) string {
+		if n == 0 {
+			return ""
+		}
+		return fmt.Sprintf("%s%d", symbol, n)
+	}
+
+	if slices.Contains(statusIndicators, indicator) && state.status == nil {
+		return ""
+	}
+	status := state.status
+
+	switch indicator {
+	case "branch":
+		// A rebase detaches HEAD; show the branch being rebased instead
+		branch := state.head.Branch
+		if state.operation != nil && state.operation.Branch != "" {
+			branch = state.operation.Branch
+		} else if state.head.Detached {
+			return state.head.ShortCommit()
+		}
+		branch, state.ticket = state.branch.rewrite(branch)
+		return branch
+	case "tag":
+		if state.head.Commit == "" {
+			return ""
+		}
+		tag, distance, err := state.repo.NearestTag(state.head.Commit)
+		if err != nil || tag == "" {
+			return ""
+		}
+		if distance > 0 {
+			return fmt.Sprintf("🏷%s+%d", tag, distance)
+		}
+		return "🏷" + tag
+	case "operation":
+		if state.operation == nil {
+			return ""
+		}
+		return state.operation.String()
+	case "worktree":
+		if state.repo.Worktree == "" {
+			return ""
+		}
+		return "wt:" + state.repo.Worktree
+	case "ahead_behind":
+		return strings.TrimSpace(count("⇡", status.Ahead) + " " + count("⇣", status.Behind))
+	case "staged":
+		return count("+", status.Staged)
+	case "unstaged":
+		return count("!", status.Unstaged)
+	case "untracked":
+		return count("?", status.Untracked)
+	case "conflicted":
+		return count("=", status.Conflicted)
+	case "stash":
+		return count("$", status.Stashes)
+	default:
+		log.Printf("Warning: unknown git indicator %q", indicator)
+		return ""
+	}
+}
+
+// branchRewriter applies a BranchConfig to branch names. Invalid expressions
+// are logged and skipped.
+type branchRewriter struct {
+	cfg      config.BranchConfig
+	ticket   *regexp.Regexp
+	patterns []*regexp.Regexp
+	with     []string
+}
+
+func newBranchRewriter(cfg config.BranchConfig) *branchRewriter {
+	rewriter := &branchRewriter{cfg: cfg}
+	if cfg.Ticket != "" {
+		ticket, err := regexp.Compile(cfg.Ticket)
+		if err != nil {
+			log.Printf("Warning: invalid ticket pattern %q: %v", cfg.Ticket, err)
+		}
+		rewriter.ticket = ticket
+	}
+	for _, replacement := range cfg.Replace {
+		pattern, err := regexp.Compile(replacement.Pattern)
+		if err != nil {
+			log.Printf("Warning: invalid branch pattern %q: %v", replacement.Pattern, err)
+			continue
+		}
+		rewriter.patterns = append(rewriter.patterns, pattern)
+		rewriter.with = append(rewriter.with, replacement.With)
+	}
+	return rewriter
+}
+
+// rewrite returns the display name of branch and the ticket key extracted
+// from it, if any.
+func (b *branchRewriter) rewrite(branch string) (string, string) {
+	var ticket string
+	if b.ticket != nil {
+		if match := b.ticket.FindStringSubmatchIndex(branch); match != nil {
+			ticket = branch[match[0]:match[1]]
+			if len(match) > 2 && match[2] >= 0 {
+				ticket = branch[match[2]:match[3]]
+			}
+			branch = joinAround(branch[:match[0]], branch[match[1]:])
+		}
+	}
+
+	for _, prefix := range b.cfg.StripPrefixes {
+		if rest, ok := strings.CutPrefix(branch, prefix); ok && rest != "" {
+			branch = rest
+			break
+		}
+	}
+
+	for i, pattern := range b.patterns {
+		branch = pattern.ReplaceAllString(branch, b.with[i])
+	}
+
+	return truncateMiddle(branch, b.cfg.MaxLength), ticket
+}
+
+// joinAround joins what is left of a branch name after a ticket key is cut
+// out, dropping the separators that surrounded it.
+func joinAround(before, after string) string {
+	before = strings.TrimRight(before, "-_")
+	after = strings.TrimLeft(after, "-_/")
+	if before == "" || after == "" || strings.HasSuffix(before, "/") {
+		return strings.TrimRight(before+after, "/")
+	}
+	return before + "-" + after
+}
+
+// truncateMiddle shortens s to max runes by replacing its middle with an
+// ellipsis, keeping both the start and the distinguishing end of the name.
+func truncateMiddle(s string, max int) string {
+	runes := []rune(s)
+	if max <= 0 || len(runes) <= max {
+		return s
+	}
+	if max == 1 {
+		return "…"
+	}
+	head := max / 2
+	tail := (max - 1) / 2
+	return string(runes[:head]) + "…" + string(runes[len(runes)-tail:])
+}
+
+// newFreshnessWidget shows how stale the branch is: the age of the last
+// commit, unpushed commits, commits since diverging from the base branch and
+// the age of the last fetch.
+func newFreshnessWidget(cfg config.FreshnessConfig) widgetFunc {
+	return func(claudeContext *claude.Context) *util.Segment {
+		if claudeContext == nil || claudeContext.WorkingDir == "" {
+			return nil
+		}
+
+		repo, err := git.Discover(claudeContext.WorkingDir)
+		if err != nil {
+			return nil
+		}
+		head, err := repo.Head()
+		if err != nil || head.Commit == "" {
+			return nil
+		}
+
+		now := time.Now()
+		var unpushed int
+		var parts []string
+		for _, view := range cfg.Show {
+			switch view {
+			case "commit":
+				if committed, err := repo.CommitTime(head.Commit); err == nil {
+					parts = append(parts, "commit "+ago(now.Sub(committed)))
+				}
+			case "unpushed":
+				if !repo.HasRemotes() {
+					continue
+				}
+				if n, err := repo.Unpushed(); err == nil && n > 0 {
+					unpushed = n
+					parts = append(parts, fmt.Sprintf("%d unpushed", n))
+				}
+			case "diverged":
+				base := repo.BaseRef()
+				if base == "" {
+					continue
+				}
+				if n, err := repo.CommitsSince(base); err == nil && n > 0 {
+					name := strings.TrimPrefix(strings.TrimPrefix(base, "refs/remotes/"), "refs/heads/")
+					parts = append(parts, fmt.Sprintf("%d since %s", n, name))
+				}
+			case "fetch":
+				if fetched := repo.LastFetch(); !fetched.IsZero() {
+					parts = append(parts, "fetched "+ago(now.Sub(fetched)))
+				} else if repo.HasRemotes() {
+					parts = append(parts, "never fetched")
+				}
+			default:
+				log.Printf("Warning: unknown freshness view %q", view)
+			}
+		}
+		if len(parts) == 0 {
+			return nil
+		}
+
+		fg, bg := cfg.Thresholds.Colors(float64(unpushed), "#ffffff", "#5f5f87")
+		return util.NewSegment("🌱", strings.Join(parts, " · "), fg, bg)
+	}
+}
+
+// ago renders an age, e.g. "2h 5m ago".
+func ago(d time.Duration) string {
+	if d < time.Minute {
+		return "just now"
+	}
+	return util.FormatDuration(d) + " ago"
+}
+
+// newDiffWidget shows the size of the uncommitted diff, which unlike the
+// lines widget includes changes made outside Claude Code.
+func newDiffWidget(cfg config.DiffConfig) widgetFunc {
+	maxAge := time.Duration(cfg.MaxAgeSeconds) * time.Second
+
+	return func(claudeContext *claude.Context) *util.Segment {
+		if claudeContext == nil || claudeContext.WorkingDir == "" {
+			return nil
+		}
+
+		repo, err := git.Discover(claudeContext.WorkingDir)
+		if err != nil || repo.Bare {
+			return nil
+		}
+		stat, err := repo.DiffStat(cfg.Exclude, maxAge)
+		if err != nil || stat.Files == 0 {
+			return nil
+		}
+
+		files := "files"
+		if stat.Files == 1 {
+			files = "file"
+		}
+		text := fmt.Sprintf("%d %s +%d -%d", stat.Files, files, stat.Added, stat.Removed)
+		fg, bg := cfg.Thresholds.Colors(float64(stat.Added+stat.Removed), "#ffffff", "#3a5f3a")
+		return util.NewSegment("±", text, fg, bg)
+	}
+}
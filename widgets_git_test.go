@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/CS-5/cstatus/config"
+)
+
+func TestBranchRewrite(t *testing.T) {
+	jira := config.BranchConfig{
+		Ticket:        "[A-Z]+-[0-9]+",
+		StripPrefixes: []string{"feature/", "fix/"},
+		MaxLength:     24,
+	}
+
+	tests := []struct {
+		name       string
+		cfg        config.BranchConfig
+		branch     string
+		want       string
+		wantTicket string
+	}{
+		{
+			name:       "ticket after a prefix",
+			cfg:        jira,
+			branch:     "feature/JIRA-1234-long-description-here",
+			want:       "long-description-here",
+			wantTicket: "JIRA-1234",
+		},
+		{
+			name:       "ticket at the end",
+			cfg:        jira,
+			branch:     "bugfix/login-PROJ-7",
+			want:       "bugfix/login",
+			wantTicket: "PROJ-7",
+		},
+		{
+			name:       "ticket in the middle",
+			cfg:        config.BranchConfig{Ticket: "[A-Z]+-[0-9]+"},
+			branch:     "hotfix_OPS-99_disk-full",
+			want:       "hotfix-disk-full",
+			wantTicket: "OPS-99",
+		},
+		{
+			name:   "no ticket",
+			cfg:    jira,
+			branch: "feature/dark-mode",
+			want:   "dark-mode",
+		},
+		{
+			name:       "ticket group",
+			cfg:        config.BranchConfig{Ticket: "#([0-9]+)"},
+			branch:     "fix-#42-crash",
+			want:       "fix-crash",
+			wantTicket: "42",
+		},
+		{
+			name:   "prefix is the whole name",
+			cfg:    jira,
+			branch: "feature/",
+			want:   "feature/",
+		},
+		{
+			name: "replacements in order",
+			cfg: config.BranchConfig{Replace: config.Replacements{
+				{Pattern: "^users/[^/]+/", With: ""},
+				{Pattern: "-(v[0-9]+)$", With: "@$1"},
+			}},
+			branch: "users/dev/cache-fix-v2",
+			want:   "cache-fix@v2",
+		},
+		{
+			name:   "truncated",
+			cfg:    jira,
+			branch: "feature/rework-the-billing-export-pipeline",
+			want:   "rework-the-b…rt-pipeline",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ticket := newBranchRewriter(test.cfg).rewrite(test.branch)
+			if got != test.want || ticket != test.wantTicket {
+				t.Errorf("rewrite(%q) = %q, %q, want %q, %q", test.branch, got, ticket, test.want, test.wantTicket)
+			}
+		})
+	}
+}
+
+func TestJoinAround(t *testing.T) {
+	tests := []struct {
+		before, after, want string
+	}{
+		{"feature/", "-login", "feature/login"},
+		{"login-", "", "login"},
+		{"", "_login", "login"},
+		{"fix-", "-crash", "fix-crash"},
+		{"feature/", "", "feature"},
+		{"", "/", ""},
+	}
+	for _, test := range tests {
+		if got := joinAround(test.before, test.after); got != test.want {
+			t.Errorf("joinAround(%q, %q) = %q, want %q", test.before, test.after, got, test.want)
+		}
+	}
+}
+
+func TestTruncateMiddle(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"long-description", 0, "long-description"},
+		{"long-description", -1, "long-description"},
+		{"long-description", 16, "long-description"},
+		{"long-description", 1, "…"},
+		{"long-description", 2, "l…"},
+		{"long-description", 3, "l…n"},
+		{"long-description", 8, "long…ion"},
+		{"größen-änderung", 5, "gr…ng"},
+	}
+	for _, test := range tests {
+		got := truncateMiddle(test.s, test.max)
+		if got != test.want {
+			t.Errorf("truncateMiddle(%q, %d) = %q, want %q", test.s, test.max, got, test.want)
+		}
+		if test.max > 0 && len([]rune(got)) > test.max {
+			t.Errorf("truncateMiddle(%q, %d) is %d runes long", test.s, test.max, len([]rune(got)))
+		}
+	}
+}
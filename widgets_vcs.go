@@ -0,0 +1,167 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/CS-5/cstatus/claude"
+	"github.com/CS-5/cstatus/config"
+	"github.com/CS-5/cstatus/git"
+	"github.com/CS-5/cstatus/hg"
+	"github.com/CS-5/cstatus/jj"
+	"github.com/CS-5/cstatus/util"
+)
+
+// vcsBackend is a kind of repository the vcs widget can report on.
+type vcsBackend interface {
+	// root returns the top of the repository containing dir, or "" when dir
+	// is not in one.
+	root(dir string) string
+	// render returns the widget's segment for the repository at root, or nil
+	// when its state cannot be read, e.g. because the tool is not installed.
+	render(claudeContext *claude.Context, root string) *util.Segment
+}
+
+// newVCSWidget reports on whichever repository contains the working
+// directory. When several do, the innermost wins and ties go to the backend
+// listed first, so a jj repository colocated with git is shown as jj. If a
+// backend cannot render, the next candidate is tried.
+func newVCSWidget(cfg *config.Config, links *linker) widgetFunc {
+	branch := newBranchRewriter(cfg.Git.Branch)
+	available := map[string]vcsBackend{
+		"git": gitBackend{widget: newGitStatusWidget(cfg.Git, links)},
+		"jj":  jjBackend{cfg: cfg.Git, branch: branch},
+		"hg":  hgBackend{cfg: cfg.Git, branch: branch},
+	}
+
+	return func(claudeContext *claude.Context) *util.Segment {
+		if claudeContext == nil || claudeContext.WorkingDir == "" {
+			return nil
+		}
+
+		type candidate struct {
+			backend vcsBackend
+			root    string
+		}
+		var candidates []candidate
+		for _, name := range cfg.VCS.Backends {
+			backend, ok := available[name]
+			if !ok {
+				log.Printf("Warning: unknown vcs backend %q", name)
+				continue
+			}
+			if root := backend.root(claudeContext.WorkingDir); root != "" {
+				candidates = append(candidates, candidate{backend, root})
+			}
+		}
+
+		// Every root contains the working directory, so the longest is innermost
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return len(candidates[i].root) > len(candidates[j].root)
+		})
+		for _, candidate := range candidates {
+			if segment := candidate.backend.render(claudeContext, candidate.root); segment != nil {
+				return segment
+			}
+		}
+		return nil
+	}
+}
+
+type gitBackend struct {
+	widget widgetFunc
+}
+
+func (b gitBackend) root(dir string) string {
+	repo, err := git.Discover(dir)
+	if err != nil {
+		return ""
+	}
+	if repo.Bare {
+		return repo.GitDir
+	}
+	return repo.WorkTree
+}
+
+func (b gitBackend) render(claudeContext *claude.Context, root string) *util.Segment {
+	return b.widget(claudeContext)
+}
+
+type jjBackend struct {
+	cfg    config.GitConfig
+	branch *branchRewriter
+}
+
+func (b jjBackend) root(dir string) string {
+	root, _ := jj.Discover(dir)
+	return root
+}
+
+func (b jjBackend) render(claudeContext *claude.Context, root string) *util.Segment {
+	status, err := jj.ReadStatus(root)
+	if err != nil {
+		return nil
+	}
+
+	var parts []string
+	var ticket string
+	if len(status.Bookmarks) > 0 {
+		var name string
+		name, ticket = b.branch.rewrite(status.Bookmarks[0])
+		parts = append(parts, name)
+	}
+	parts = append(parts, status.ChangeID)
+
+	fg, bg := "#ffffff", "#7b5fb0"
+	if !status.Empty {
+		fg, bg = b.cfg.DirtyFg, b.cfg.DirtyBg
+	}
+	if status.Conflict {
+		parts = append(parts, "conflict")
+		fg, bg = "#ffffff", "#cc3333"
+	}
+	return vcsSegment("◉", parts, ticket, fg, bg, b.cfg.Branch)
+}
+
+type hgBackend struct {
+	cfg    config.GitConfig
+	branch *branchRewriter
+}
+
+func (b hgBackend) root(dir string) string {
+	root, _ := hg.Discover(dir)
+	return root
+}
+
+func (b hgBackend) render(claudeContext *claude.Context, root string) *util.Segment {
+	status, err := hg.ReadStatus(root)
+	if err != nil {
+		return nil
+	}
+
+	name, ticket := b.branch.rewrite(status.Branch)
+	parts := []string{name}
+	if status.Bookmark != "" {
+		parts = append(parts, "*"+status.Bookmark)
+	}
+
+	// Without hg the working directory may be dirty; don't show it as clean
+	fg, bg := "#ffffff", "#5f8787"
+	if !status.DirtyKnown {
+		fg, bg = "#ffffff", "#6c6c6c"
+	} else if status.Dirty {
+		fg, bg = b.cfg.DirtyFg, b.cfg.DirtyBg
+	}
+	return vcsSegment("☿", parts, ticket, fg, bg, b.cfg.Branch)
+}
+
+// vcsSegment builds a vcs widget segment, with the ticket key extracted from
+// the branch in its own sub-segment.
+func vcsSegment(icon string, parts []string, ticket, fg, bg string, cfg config.BranchConfig) *util.Segment {
+	segment := util.NewSegment(icon, strings.Join(parts, " "), fg, bg)
+	if ticket != "" {
+		segment.Append(util.NewSegment("🎫", ticket, cfg.TicketFg, cfg.TicketBg))
+	}
+	return segment
+}